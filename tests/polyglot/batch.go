@@ -0,0 +1,146 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "sort"
+)
+
+// KeyError is the failure of a single key within a batch.
+type KeyError struct {
+    Key string
+    Err error
+}
+
+func (e *KeyError) Error() string { return fmt.Sprintf("key %q: %v", e.Key, e.Err) }
+func (e *KeyError) Unwrap() error { return e.Err }
+
+// FailedKeys returns the keys of every KeyError in err's tree, so callers can
+// retry only those.
+func FailedKeys(err error) []string {
+    var keys []string
+    for _, ke := range keyErrors(err) {
+        keys = append(keys, ke.Key)
+    }
+    return keys
+}
+
+func keyErrors(err error) []*KeyError {
+    var found []*KeyError
+    var walk func(error)
+    walk = func(err error) {
+        switch e := err.(type) {
+        case nil:
+        case *KeyError:
+            found = append(found, e)
+        case interface{ Unwrap() []error }:
+            for _, err := range e.Unwrap() {
+                walk(err)
+            }
+        default:
+            walk(errors.Unwrap(err))
+        }
+    }
+    walk(err)
+    return found
+}
+
+// batchErrors maps each key of items that err reports as failed to its
+// error. An err without KeyErrors fails every key.
+func batchErrors(err error, items map[string]string) map[string]error {
+    errs := make(map[string]error)
+    if err == nil {
+        return errs
+    }
+    kerrs := keyErrors(err)
+    if len(kerrs) == 0 {
+        for k := range items {
+            errs[k] = err
+        }
+    }
+    for _, ke := range kerrs {
+        errs[ke.Key] = ke.Err
+    }
+    return errs
+}
+
+// batchError joins the per-key failures of a non-atomic batch.
+func batchError(failed []error, total int) error {
+    if len(failed) == 0 {
+        return nil
+    }
+    return fmt.Errorf("%d of %d keys failed (batch is not atomic, the other keys were saved): %w",
+        len(failed), total, errors.Join(failed...))
+}
+
+func sortedKeys[V any](items map[string]V) []string {
+    keys := make([]string, 0, len(items))
+    for k := range items {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    return keys
+}
+
+// DeadLetter is what WithDeadLetter stores, as JSON, under the key of an item
+// SaveBatch failed to save.
+type DeadLetter struct {
+    Value string `json:"value"`
+    Error string `json:"error"`
+}
+
+// SaveBatch writes items under a single lock acquisition. The batch is not
+// atomic: keys rejected by the size limits, or not yet written when ctx is
+// cancelled, are reported as KeyErrors and the rest stay saved.
+func (db *Database) SaveBatch(ctx context.Context, items map[string]string) error {
+    err := db.run(ctx, "save_batch", "", func(ctx context.Context) error {
+        return db.saveBatch(ctx, items)
+    })
+    if err != nil && db.opts.DeadLetter != nil {
+        err = errors.Join(err, db.deadLetter(ctx, items, err))
+    }
+    return err
+}
+
+// deadLetter records the items that failed in err: those of its KeyErrors,
+// or all of them if the whole batch failed. It ignores the cancellation of
+// ctx, which may be why they failed. Failed dead-letter writes are reported
+// as ErrDeadLetter.
+func (db *Database) deadLetter(ctx context.Context, items map[string]string, err error) error {
+    ctx = context.WithoutCancel(ctx)
+    failed := batchErrors(err, items)
+    var errs []error
+    for _, k := range sortedKeys(failed) {
+        dl := DeadLetter{Value: items[k], Error: failed[k].Error()}
+        if err := SaveJSON(ctx, db.opts.DeadLetter, k, dl); err != nil {
+            errs = append(errs, fmt.Errorf("%w: key %q: %w", ErrDeadLetter, k, err))
+        }
+    }
+    return errors.Join(errs...)
+}
+
+func (db *Database) saveBatch(ctx context.Context, items map[string]string) error {
+    db.mu.Lock()
+    defer db.mu.Unlock()
+    if db.closed {
+        return ErrClosed
+    }
+    var failed []error
+    for _, k := range sortedKeys(items) {
+        if err := ctx.Err(); err != nil {
+            failed = append(failed, &KeyError{Key: k, Err: err})
+            continue
+        }
+        key, err := db.key(k)
+        if err == nil {
+            err = db.checkPut(key, len(items[k]))
+        }
+        if err != nil {
+            failed = append(failed, &KeyError{Key: k, Err: err})
+            continue
+        }
+        db.put(key, []byte(items[k]), 0)
+    }
+    return batchError(failed, len(items))
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+    "bytes"
+    "context"
+)
+
+// SaveBytes saves a copy of data under key. Values are stored as bytes, so
+// SaveBytes and Save, and LoadBytes and Load, see the same data.
+func (db *Database) SaveBytes(ctx context.Context, key string, data []byte) error {
+    return db.run(ctx, "save", key, func(ctx context.Context) error {
+        _, err := db.save(ctx, key, bytes.Clone(data), 0)
+        return err
+    })
+}
+
+// LoadBytes returns a copy of the value stored under key.
+func (db *Database) LoadBytes(ctx context.Context, key string) ([]byte, error) {
+    var data []byte
+    err := db.run(ctx, "load", key, func(ctx context.Context) error {
+        v, err := db.load(ctx, key)
+        if err != nil {
+            return err
+        }
+        data = bytes.Clone(v)
+        return nil
+    })
+    return data, err
+}
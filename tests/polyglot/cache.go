@@ -0,0 +1,365 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "slices"
+    "sync"
+    "time"
+)
+
+type WritePolicy int
+
+const (
+    // WriteThrough saves to the backing store, then to the cache.
+    WriteThrough WritePolicy = iota
+    // WriteBack saves only to the cache and defers the backing write until
+    // Flush. Unflushed writes are lost if the process exits.
+    WriteBack
+)
+
+const defaultNegativeTTL = time.Second
+
+type CacheOption func(*CacheStorage)
+
+func WithWritePolicy(p WritePolicy) CacheOption {
+    return func(c *CacheStorage) { c.policy = p }
+}
+
+// WithNegativeTTL sets how long a key missing from the backing store is
+// remembered as missing. Zero disables negative caching.
+func WithNegativeTTL(d time.Duration) CacheOption {
+    return func(c *CacheStorage) { c.negativeTTL = d }
+}
+
+// CacheStorage serves loads from cache and falls back to backing on a miss,
+// populating cache with the result. A load that races with a write to the
+// same key returns what it read but neither caches it nor records a miss.
+type CacheStorage struct {
+    cache       Storage
+    backing     Storage
+    policy      WritePolicy
+    negativeTTL time.Duration
+
+    mu       sync.Mutex            // also held while a read-through populates cache
+    missing  map[string]time.Time  // keys known absent from backing, until the time
+    dirty    map[string]dirtyValue // write-back values not yet in backing
+    inflight map[string]*keyOps    // keys with loads or writes in progress
+    gen      uint64
+}
+
+// dirtyValue is a write-back value kept until Flush saves it, so that it
+// survives the cache evicting or expiring it.
+type dirtyValue struct {
+    data string
+    gen  uint64 // write generation, to tell if it was written again
+}
+
+// keyOps counts the operations in progress on one key.
+type keyOps struct {
+    mu            sync.Mutex // held by the write in progress, see lockWrite
+    loads, writes int
+    wrote         uint64 // generation of the last write to start
+}
+
+var _ Storage = (*CacheStorage)(nil)
+
+func NewCacheStorage(cache, backing Storage, opts ...CacheOption) *CacheStorage {
+    c := &CacheStorage{
+        cache:       cache,
+        backing:     backing,
+        negativeTTL: defaultNegativeTTL,
+        missing:     make(map[string]time.Time),
+        dirty:       make(map[string]dirtyValue),
+        inflight:    make(map[string]*keyOps),
+    }
+    for _, opt := range opts {
+        opt(c)
+    }
+    return c
+}
+
+func (c *CacheStorage) Save(key, data string) (uint64, error) {
+    return c.SaveContext(context.Background(), key, data)
+}
+
+// SaveContext returns the revision from the backing store, or from the cache
+// under WriteBack.
+func (c *CacheStorage) SaveContext(ctx context.Context, key, data string) (uint64, error) {
+    defer c.lockWrite(key)()
+    var rev uint64
+    if c.policy == WriteThrough {
+        var err error
+        if rev, err = saveContext(ctx, c.backing, key, data); err != nil {
+            return 0, err
+        }
+    }
+    cacheRev, err := saveContext(ctx, c.cache, key, data)
+    if err != nil {
+        return 0, err
+    }
+    if c.policy == WriteBack {
+        rev = cacheRev
+    }
+    c.mu.Lock()
+    delete(c.missing, key)
+    if c.policy == WriteBack {
+        c.gen++
+        c.dirty[key] = dirtyValue{data: data, gen: c.gen}
+    }
+    c.mu.Unlock()
+    return rev, nil
+}
+
+func (c *CacheStorage) Load(key string) (string, error) {
+    return c.LoadContext(context.Background(), key)
+}
+
+func (c *CacheStorage) LoadContext(ctx context.Context, key string) (string, error) {
+    if data, ok := c.unflushed(key); ok {
+        return data, nil
+    }
+    if c.knownMissing(key) {
+        return "", ErrNotFound
+    }
+    data, err := loadContext(ctx, c.cache, key)
+    if !errors.Is(err, ErrNotFound) {
+        return data, err
+    }
+    gen := c.beginLoad(key)
+    data, err = loadContext(ctx, c.backing, key)
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if !c.endLoad(key, gen) {
+        return data, err
+    }
+    if errors.Is(err, ErrNotFound) && c.negativeTTL > 0 {
+        c.missing[key] = time.Now().Add(c.negativeTTL)
+    }
+    if err != nil {
+        return "", err
+    }
+    // Populated under c.mu, so no write to key can start before it lands. A
+    // failure to populate the cache does not fail the load.
+    _, _ = saveContext(ctx, c.cache, key, data)
+    return data, nil
+}
+
+func (c *CacheStorage) Has(ctx context.Context, key string) (bool, error) {
+    if _, ok := c.unflushed(key); ok {
+        return true, nil
+    }
+    if c.knownMissing(key) {
+        return false, nil
+    }
+    if ok, err := c.cache.Has(ctx, key); err != nil || ok {
+        return ok, err
+    }
+    gen := c.beginLoad(key)
+    ok, err := c.backing.Has(ctx, key)
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if c.endLoad(key, gen) && err == nil && !ok && c.negativeTTL > 0 {
+        c.missing[key] = time.Now().Add(c.negativeTTL)
+    }
+    return ok, err
+}
+
+// beginLoad marks a read-through of key as in progress and returns the
+// current write generation.
+func (c *CacheStorage) beginLoad(key string) uint64 {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.ops(key).loads++
+    return c.gen
+}
+
+// endLoad ends a read-through that began at gen and reports whether no write
+// to key started since, so that what it read may be cached. Callers hold c.mu.
+func (c *CacheStorage) endLoad(key string, gen uint64) bool {
+    op := c.inflight[key]
+    op.loads--
+    c.release(key, op)
+    return op.writes == 0 && op.wrote <= gen
+}
+
+// lockWrite takes the per-key locks of keys and marks writes to them as in
+// progress, then returns the func that undoes both. Writes to one key are
+// serialized so that cache and backing end up with the same value; keys are
+// locked in sorted order, so batches cannot deadlock.
+func (c *CacheStorage) lockWrite(keys ...string) (unlock func()) {
+    keys = slices.Compact(slices.Sorted(slices.Values(keys)))
+    held := make([]*keyOps, len(keys))
+    c.mu.Lock()
+    c.gen++
+    for i, k := range keys {
+        op := c.ops(k)
+        op.writes++
+        op.wrote = c.gen
+        held[i] = op
+    }
+    c.mu.Unlock()
+    for _, op := range held {
+        op.mu.Lock()
+    }
+    return func() {
+        c.mu.Lock()
+        defer c.mu.Unlock()
+        for i, op := range held {
+            op.mu.Unlock()
+            op.writes--
+            c.release(keys[i], op)
+        }
+    }
+}
+
+// ops returns the in-progress record of key, creating it. Callers hold c.mu.
+func (c *CacheStorage) ops(key string) *keyOps {
+    op, ok := c.inflight[key]
+    if !ok {
+        op = &keyOps{}
+        c.inflight[key] = op
+    }
+    return op
+}
+
+func (c *CacheStorage) release(key string, op *keyOps) {
+    if op.loads == 0 && op.writes == 0 {
+        delete(c.inflight, key)
+    }
+}
+
+// unflushed returns the write-back value of key that is not yet in backing.
+func (c *CacheStorage) unflushed(key string) (string, bool) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    d, ok := c.dirty[key]
+    return d.data, ok
+}
+
+func (c *CacheStorage) knownMissing(key string) bool {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    until, ok := c.missing[key]
+    if ok && time.Now().After(until) {
+        delete(c.missing, key)
+        return false
+    }
+    return ok
+}
+
+func (c *CacheStorage) SaveBatch(ctx context.Context, items map[string]string) error {
+    defer c.lockWrite(sortedKeys(items)...)()
+    target := c.backing
+    if c.policy == WriteBack {
+        target = c.cache
+    }
+    err := target.SaveBatch(ctx, items)
+    saved := make(map[string]string, len(items))
+    for k, v := range items {
+        saved[k] = v
+    }
+    for _, k := range FailedKeys(err) {
+        delete(saved, k)
+    }
+    if err != nil && len(saved) == len(items) {
+        // Not a per-key failure: nothing can be assumed saved.
+        return err
+    }
+    if c.policy == WriteThrough {
+        _ = c.cache.SaveBatch(ctx, saved)
+    }
+    c.mu.Lock()
+    for k, v := range saved {
+        delete(c.missing, k)
+        if c.policy == WriteBack {
+            c.gen++
+            c.dirty[k] = dirtyValue{data: v, gen: c.gen}
+        }
+    }
+    c.mu.Unlock()
+    return err
+}
+
+func (c *CacheStorage) Delete(key string) error {
+    defer c.lockWrite(key)()
+    if err := c.backing.Delete(key); err != nil {
+        return err
+    }
+    c.mu.Lock()
+    delete(c.dirty, key)
+    c.mu.Unlock()
+    return c.cache.Delete(key)
+}
+
+// LoadMany loads each key as LoadContext does, so misses are read through
+// and cached.
+func (c *CacheStorage) LoadMany(ctx context.Context, keys []string) (map[string]string, error) {
+    return LoadEach(ctx, c, keys)
+}
+
+func (c *CacheStorage) DeleteMany(ctx context.Context, keys []string) error {
+    defer c.lockWrite(keys...)()
+    if err := c.backing.DeleteMany(ctx, keys); err != nil {
+        return err
+    }
+    c.mu.Lock()
+    for _, k := range keys {
+        delete(c.dirty, k)
+    }
+    c.mu.Unlock()
+    return c.cache.DeleteMany(ctx, keys)
+}
+
+func (c *CacheStorage) Ping(ctx context.Context) error {
+    if err := c.cache.Ping(ctx); err != nil {
+        return err
+    }
+    return c.backing.Ping(ctx)
+}
+
+// Sync flushes pending write-back keys and then syncs the backing store.
+func (c *CacheStorage) Sync(ctx context.Context) error {
+    if err := c.Flush(ctx); err != nil {
+        return err
+    }
+    return c.backing.Sync(ctx)
+}
+
+// Flush saves every pending write-back value to the backing store, even
+// those the cache has since evicted or expired. Writes to those keys wait
+// for it. Keys that fail stay pending.
+func (c *CacheStorage) Flush(ctx context.Context) error {
+    c.mu.Lock()
+    keys := sortedKeys(c.dirty)
+    c.mu.Unlock()
+    defer c.lockWrite(keys...)()
+
+    c.mu.Lock()
+    pending := make(map[string]uint64, len(keys))
+    items := make(map[string]string, len(keys))
+    for _, k := range keys {
+        if d, ok := c.dirty[k]; ok {
+            pending[k] = d.gen
+            items[k] = d.data
+        }
+    }
+    c.mu.Unlock()
+
+    err := c.backing.SaveBatch(ctx, items)
+    if err != nil && len(FailedKeys(err)) == 0 {
+        return err
+    }
+    failed := make(map[string]bool)
+    for _, k := range FailedKeys(err) {
+        failed[k] = true
+    }
+    c.mu.Lock()
+    for k, gen := range pending {
+        if !failed[k] && c.dirty[k].gen == gen {
+            delete(c.dirty, k)
+        }
+    }
+    c.mu.Unlock()
+    return err
+}
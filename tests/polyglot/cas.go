@@ -0,0 +1,61 @@
+package main
+
+import (
+    "context"
+    "time"
+)
+
+// CompareAndSwap saves new under key only if the current value is old. It
+// reports false, with a nil error, when the value differs or key is absent.
+func (db *Database) CompareAndSwap(ctx context.Context, key, old, new string) (bool, error) {
+    var swapped bool
+    err := db.run(ctx, "cas", key, func(ctx context.Context) (err error) {
+        swapped, err = db.swap(ctx, key, new, func(cur entry, ok bool) bool {
+            return ok && string(cur.value) == old
+        })
+        return err
+    })
+    return swapped, err
+}
+
+// SaveIfAbsent saves data under key only if key has no live value.
+func (db *Database) SaveIfAbsent(ctx context.Context, key, data string) (bool, error) {
+    var saved bool
+    err := db.run(ctx, "save_if_absent", key, func(ctx context.Context) (err error) {
+        saved, err = db.swap(ctx, key, data, func(_ entry, ok bool) bool {
+            return !ok
+        })
+        return err
+    })
+    return saved, err
+}
+
+// swap saves data under key if cond accepts the current entry. The check
+// and the write happen under one write lock, so they are atomic with respect
+// to every other operation.
+func (db *Database) swap(ctx context.Context, key, data string, cond func(cur entry, ok bool) bool) (bool, error) {
+    key, err := db.key(key)
+    if err != nil {
+        return false, err
+    }
+    db.mu.Lock()
+    defer db.mu.Unlock()
+    if db.closed {
+        return false, ErrClosed
+    }
+    if err := ctx.Err(); err != nil {
+        return false, err
+    }
+    cur, ok := db.data[key]
+    if ok && cur.expired(time.Now()) {
+        ok = false
+    }
+    if !cond(cur, ok) {
+        return false, nil
+    }
+    if err := db.checkPut(key, len(data)); err != nil {
+        return false, err
+    }
+    db.put(key, []byte(data), 0)
+    return true, nil
+}
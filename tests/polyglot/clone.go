@@ -0,0 +1,35 @@
+package main
+
+import (
+    "bytes"
+    "time"
+)
+
+// Clone returns a new Database with the same options and a copy of every
+// live entry, TTLs included. The two share no state afterwards: writes to
+// one are not seen by the other, and the clone starts without subscribers.
+func (db *Database) Clone() (*Database, error) {
+    db.mu.RLock()
+    defer db.mu.RUnlock()
+    if db.closed {
+        return nil, &OpError{Op: "clone", Err: ErrClosed}
+    }
+    c := newDatabase(db.URI, db.opts)
+    now := time.Now()
+    for k, e := range db.data {
+        if !e.expired(now) {
+            c.data[k] = entry{value: bytes.Clone(e.value), expires: e.expires, rev: e.rev}
+        } else {
+            c.tombs[k] = e.rev
+        }
+    }
+    for k, rev := range db.tombs {
+        c.tombs[k] = rev
+    }
+    if c.lru != nil {
+        db.lruMu.Lock()
+        c.relink(db.lru)
+        db.lruMu.Unlock()
+    }
+    return c, nil
+}
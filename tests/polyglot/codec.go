@@ -0,0 +1,74 @@
+package main
+
+import (
+    "encoding/gob"
+    "encoding/json"
+    "fmt"
+    "io"
+    "unicode/utf8"
+)
+
+// Codec encodes the key and value of one FileStorage record. FileStorage
+// frames each record itself, so Encode may write and Decode may read to the
+// end of the stream they are given. Name identifies the format in the file
+// header and must not change once files have been written with it.
+type Codec interface {
+    Name() string
+    Encode(w io.Writer, key, value string) error
+    Decode(r io.Reader) (key, value string, err error)
+}
+
+// JSONCodec stores records as JSON objects, which are easy to inspect. It
+// refuses keys and values that are not valid UTF-8 rather than altering
+// them; use GobCodec for arbitrary bytes.
+type JSONCodec struct{}
+
+type jsonRecord struct {
+    Key   string `json:"key"`
+    Value string `json:"value"`
+}
+
+func (JSONCodec) Name() string { return "json" }
+
+func (JSONCodec) Encode(w io.Writer, key, value string) error {
+    if !utf8.ValidString(key) {
+        return fmt.Errorf("json codec: key %q is not valid UTF-8", key)
+    }
+    if !utf8.ValidString(value) {
+        return fmt.Errorf("json codec: value of key %q is not valid UTF-8", key)
+    }
+    return json.NewEncoder(w).Encode(jsonRecord{Key: key, Value: value})
+}
+
+func (JSONCodec) Decode(r io.Reader) (string, string, error) {
+    var rec jsonRecord
+    if err := json.NewDecoder(r).Decode(&rec); err != nil {
+        return "", "", err
+    }
+    return rec.Key, rec.Value, nil
+}
+
+// GobCodec stores records with encoding/gob and is the FileStorage default.
+// It is more compact than JSONCodec and keeps keys and values byte for byte.
+type GobCodec struct{}
+
+func (GobCodec) Name() string { return "gob" }
+
+func (GobCodec) Encode(w io.Writer, key, value string) error {
+    enc := gob.NewEncoder(w)
+    if err := enc.Encode(key); err != nil {
+        return err
+    }
+    return enc.Encode(value)
+}
+
+func (GobCodec) Decode(r io.Reader) (key, value string, err error) {
+    dec := gob.NewDecoder(r)
+    if err := dec.Decode(&key); err != nil {
+        return "", "", err
+    }
+    if err := dec.Decode(&value); err != nil {
+        return "", "", err
+    }
+    return key, value, nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+    "bytes"
+    "compress/gzip"
+    "context"
+    "encoding/base64"
+    "fmt"
+    "io"
+    "strings"
+)
+
+// Every value written by CompressStorage starts with one of these headers.
+// They begin with a NUL byte so that text saved past the decorator is not
+// mistaken for one.
+const (
+    headerRaw  = "\x00raw" // the rest is the value as saved
+    headerGzip = "\x00gz1" // the rest is the base64 of the gzipped value
+)
+
+const defaultCompressThreshold = 1024
+
+type CompressOption func(*CompressStorage)
+
+// WithThreshold sets the smallest value, in bytes, that is compressed.
+func WithThreshold(n int) CompressOption {
+    return func(c *CompressStorage) { c.threshold = n }
+}
+
+// WithCompressionLevel sets the gzip level, gzip.DefaultCompression unless
+// set.
+func WithCompressionLevel(level int) CompressOption {
+    return func(c *CompressStorage) { c.level = level }
+}
+
+// CompressStorage gzips values of at least the threshold before saving them
+// and inflates them again on load. Compressed values are base64 encoded so
+// they stay valid text for backends like FileStorage. Values that do not
+// shrink are stored as they are.
+type CompressStorage struct {
+    inner     Storage
+    threshold int
+    level     int
+}
+
+var _ Storage = (*CompressStorage)(nil)
+
+func NewCompressStorage(s Storage, opts ...CompressOption) *CompressStorage {
+    c := &CompressStorage{inner: s, threshold: defaultCompressThreshold, level: gzip.DefaultCompression}
+    for _, opt := range opts {
+        opt(c)
+    }
+    return c
+}
+
+func (c *CompressStorage) encode(data string) (string, error) {
+    if len(data) < c.threshold {
+        return headerRaw + data, nil
+    }
+    var buf bytes.Buffer
+    zw, err := gzip.NewWriterLevel(&buf, c.level)
+    if err != nil {
+        return "", err
+    }
+    if _, err := io.WriteString(zw, data); err != nil {
+        return "", err
+    }
+    if err := zw.Close(); err != nil {
+        return "", err
+    }
+    if base64.StdEncoding.EncodedLen(buf.Len()) >= len(data) {
+        return headerRaw + data, nil
+    }
+    return headerGzip + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+func (c *CompressStorage) decode(key, stored string) (string, error) {
+    if body, ok := strings.CutPrefix(stored, headerRaw); ok {
+        return body, nil
+    }
+    body, ok := strings.CutPrefix(stored, headerGzip)
+    if !ok {
+        return "", fmt.Errorf("decompress %q: %w: missing header", key, ErrCorruptValue)
+    }
+    b, err := base64.StdEncoding.DecodeString(body)
+    if err != nil {
+        return "", fmt.Errorf("decompress %q: %w: %w", key, ErrCorruptValue, err)
+    }
+    zr, err := gzip.NewReader(bytes.NewReader(b))
+    if err != nil {
+        return "", fmt.Errorf("decompress %q: %w: %w", key, ErrCorruptValue, err)
+    }
+    data, err := io.ReadAll(zr)
+    if err != nil {
+        return "", fmt.Errorf("decompress %q: %w: %w", key, ErrCorruptValue, err)
+    }
+    return string(data), nil
+}
+
+func (c *CompressStorage) Save(key, data string) (uint64, error) {
+    return c.SaveContext(context.Background(), key, data)
+}
+
+func (c *CompressStorage) SaveContext(ctx context.Context, key, data string) (uint64, error) {
+    stored, err := c.encode(data)
+    if err != nil {
+        return 0, fmt.Errorf("compress %q: %w", key, err)
+    }
+    return saveContext(ctx, c.inner, key, stored)
+}
+
+func (c *CompressStorage) Load(key string) (string, error) {
+    return c.LoadContext(context.Background(), key)
+}
+
+func (c *CompressStorage) LoadContext(ctx context.Context, key string) (string, error) {
+    stored, err := loadContext(ctx, c.inner, key)
+    if err != nil {
+        return "", err
+    }
+    return c.decode(key, stored)
+}
+
+func (c *CompressStorage) SaveBatch(ctx context.Context, items map[string]string) error {
+    encoded := make(map[string]string, len(items))
+    for k, v := range items {
+        stored, err := c.encode(v)
+        if err != nil {
+            return fmt.Errorf("compress %q: %w", k, err)
+        }
+        encoded[k] = stored
+    }
+    return c.inner.SaveBatch(ctx, encoded)
+}
+
+func (c *CompressStorage) LoadMany(ctx context.Context, keys []string) (map[string]string, error) {
+    found, err := c.inner.LoadMany(ctx, keys)
+    if err != nil {
+        return nil, err
+    }
+    for k, stored := range found {
+        if found[k], err = c.decode(k, stored); err != nil {
+            return nil, err
+        }
+    }
+    return found, nil
+}
+
+func (c *CompressStorage) DeleteMany(ctx context.Context, keys []string) error {
+    return c.inner.DeleteMany(ctx, keys)
+}
+
+func (c *CompressStorage) Delete(key string) error {
+    return c.inner.Delete(key)
+}
+
+func (c *CompressStorage) Has(ctx context.Context, key string) (bool, error) {
+    return c.inner.Has(ctx, key)
+}
+
+func (c *CompressStorage) Ping(ctx context.Context) error {
+    return c.inner.Ping(ctx)
+}
+
+func (c *CompressStorage) Sync(ctx context.Context) error {
+    return c.inner.Sync(ctx)
+}
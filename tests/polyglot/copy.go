@@ -0,0 +1,86 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "time"
+)
+
+type copyOptions struct {
+    noOverwrite bool
+}
+
+type CopyOption func(*copyOptions)
+
+// NoOverwrite makes Copy and Move fail with ErrExists instead of replacing a
+// live value at dst.
+func NoOverwrite() CopyOption {
+    return func(o *copyOptions) { o.noOverwrite = true }
+}
+
+// Copy saves the value of src under dst as well, keeping any TTL.
+func (db *Database) Copy(ctx context.Context, src, dst string, opts ...CopyOption) error {
+    return db.run(ctx, "copy", src, func(ctx context.Context) error {
+        return db.copy(ctx, src, dst, false, opts)
+    })
+}
+
+// Move renames src to dst. Both keys change under one write lock, so no
+// reader sees the value at neither or both keys.
+func (db *Database) Move(ctx context.Context, src, dst string, opts ...CopyOption) error {
+    return db.run(ctx, "move", src, func(ctx context.Context) error {
+        return db.copy(ctx, src, dst, true, opts)
+    })
+}
+
+func (db *Database) copy(ctx context.Context, src, dst string, move bool, opts []CopyOption) error {
+    var o copyOptions
+    for _, opt := range opts {
+        opt(&o)
+    }
+    src, err := db.key(src)
+    if err != nil {
+        return err
+    }
+    k, err := db.key(dst)
+    if err != nil {
+        return fmt.Errorf("dst %q: %w", dst, err)
+    }
+    dst = k
+
+    db.mu.Lock()
+    defer db.mu.Unlock()
+    if db.closed {
+        return ErrClosed
+    }
+    if err := ctx.Err(); err != nil {
+        return err
+    }
+    now := time.Now()
+    e, ok := db.data[src]
+    if !ok || e.expired(now) {
+        return ErrNotFound
+    }
+    if src == dst {
+        return nil
+    }
+    if cur, ok := db.data[dst]; ok && !cur.expired(now) && o.noOverwrite {
+        return fmt.Errorf("dst %q: %w", dst, ErrExists)
+    }
+    // A move never adds a key, so only a copy can run into the quota.
+    if !move {
+        if err := db.checkPut(dst, len(e.value)); err != nil {
+            return err
+        }
+    }
+
+    var ttl time.Duration
+    if !e.expires.IsZero() {
+        ttl = e.expires.Sub(now)
+    }
+    db.put(dst, e.value, ttl)
+    if move {
+        db.del(src)
+    }
+    return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "math"
+    "strconv"
+    "time"
+)
+
+// Increment adds delta to the base-10 integer stored under key and returns
+// the result. A missing key counts as zero. The read and the write happen
+// under one lock, and an existing TTL is kept.
+func (db *Database) Increment(ctx context.Context, key string, delta int64) (int64, error) {
+    var n int64
+    err := db.run(ctx, "increment", key, func(ctx context.Context) error {
+        key, err := db.key(key)
+        if err != nil {
+            return err
+        }
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        if db.closed {
+            return ErrClosed
+        }
+        if err := ctx.Err(); err != nil {
+            return err
+        }
+
+        var cur int64
+        var ttl time.Duration
+        now := time.Now()
+        if e, ok := db.data[key]; ok && !e.expired(now) {
+            v, err := strconv.ParseInt(string(e.value), 10, 64)
+            if err != nil {
+                return fmt.Errorf("%w: %q", ErrNotAnInteger, e.value)
+            }
+            cur = v
+            if !e.expires.IsZero() {
+                ttl = e.expires.Sub(now)
+            }
+        }
+        if (delta > 0 && cur > math.MaxInt64-delta) || (delta < 0 && cur < math.MinInt64-delta) {
+            return fmt.Errorf("increment by %d overflows %d", delta, cur)
+        }
+
+        next := strconv.AppendInt(nil, cur+delta, 10)
+        if err := db.checkPut(key, len(next)); err != nil {
+            return err
+        }
+        db.put(key, next, ttl)
+        n = cur + delta
+        return nil
+    })
+    return n, err
+}
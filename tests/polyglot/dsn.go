@@ -0,0 +1,48 @@
+package main
+
+import (
+    "fmt"
+    "net/url"
+    "strconv"
+    "time"
+)
+
+// queryOptions sets the ConnectOptions named by the query parameters of u:
+//
+//    maxConns   MaxConns, a non-negative integer
+//    timeout    DialTimeout, a duration such as 5s
+//    ttl        DefaultTTL, a duration
+//    namespace  Namespace
+//
+// Other parameters are left for the backend to interpret.
+func queryOptions(u *url.URL, o *ConnectOptions) error {
+    q := u.Query()
+    if v := q.Get("maxConns"); v != "" {
+        n, err := strconv.Atoi(v)
+        if err != nil || n < 0 {
+            return fmt.Errorf("%w: maxConns must be a non-negative integer, got %q", ErrInvalidURI, v)
+        }
+        o.MaxConns = n
+    }
+    for _, p := range []struct {
+        name string
+        dst  *time.Duration
+    }{
+        {"timeout", &o.DialTimeout},
+        {"ttl", &o.DefaultTTL},
+    } {
+        v := q.Get(p.name)
+        if v == "" {
+            continue
+        }
+        d, err := time.ParseDuration(v)
+        if err != nil || d < 0 {
+            return fmt.Errorf("%w: %s must be a non-negative duration such as 5s, got %q", ErrInvalidURI, p.name, v)
+        }
+        *p.dst = d
+    }
+    if v := q.Get("namespace"); v != "" {
+        o.Namespace = v
+    }
+    return nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+    "errors"
+    "strconv"
+)
+
+var (
+    ErrNotFound      = errors.New("not found")
+    ErrExists        = errors.New("already exists")
+    ErrClosed        = errors.New("closed")
+    ErrInvalidURI    = errors.New("invalid uri")
+    ErrInvalidKey    = errors.New("invalid key")
+    ErrUnknownScheme = errors.New("unknown scheme")
+    ErrValueTooLarge = errors.New("value too large")
+    ErrQuotaExceeded = errors.New("key quota exceeded")
+    ErrNotAnInteger  = errors.New("value is not an integer")
+    ErrTxDone        = errors.New("transaction already committed or rolled back")
+    ErrCorruptValue  = errors.New("corrupt value")
+    ErrDeadLetter    = errors.New("dead letter write failed")
+
+    ErrRevisionMismatch = errors.New("revision mismatch")
+    ErrCodecMismatch    = errors.New("file format or codec mismatch")
+)
+
+// OpError records the operation and key that failed. Database methods return
+// their failures wrapped in one, so errors.Is still matches the sentinels.
+type OpError struct {
+    Op  string
+    Key string
+    Err error
+}
+
+func (e *OpError) Error() string {
+    if e.Key == "" {
+        return e.Op + ": " + e.Err.Error()
+    }
+    return e.Op + " key " + strconv.Quote(e.Key) + ": " + e.Err.Error()
+}
+
+func (e *OpError) Unwrap() error { return e.Err }
+
+// opError wraps a non-nil err in an OpError.
+func opError(op, key string, err error) error {
+    if err == nil {
+        return nil
+    }
+    return &OpError{Op: op, Key: key, Err: err}
+}
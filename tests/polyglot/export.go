@@ -0,0 +1,89 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "time"
+)
+
+// exportRecord is one line of the newline-delimited JSON export format.
+type exportRecord struct {
+    Key       *string    `json:"key"`
+    Value     *string    `json:"value"`
+    ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Export writes every live entry to w as newline-delimited JSON. Keys are
+// snapshotted first and each value is read under a short read lock, so writers
+// are not blocked for the whole stream; entries deleted meanwhile are skipped.
+func (db *Database) Export(ctx context.Context, w io.Writer) error {
+    db.mu.RLock()
+    if db.closed {
+        db.mu.RUnlock()
+        return &OpError{Op: "export", Err: ErrClosed}
+    }
+    keys := make([]string, 0, len(db.data))
+    for k := range db.data {
+        keys = append(keys, k)
+    }
+    db.mu.RUnlock()
+
+    enc := json.NewEncoder(w)
+    for _, k := range keys {
+        if err := ctx.Err(); err != nil {
+            return &OpError{Op: "export", Err: err}
+        }
+        db.mu.RLock()
+        e, ok := db.data[k]
+        db.mu.RUnlock()
+        if !ok || e.expired(time.Now()) {
+            continue
+        }
+        value := string(e.value)
+        rec := exportRecord{Key: &k, Value: &value}
+        if !e.expires.IsZero() {
+            rec.ExpiresAt = &e.expires
+        }
+        if err := enc.Encode(rec); err != nil {
+            return &OpError{Op: "export", Key: k, Err: err}
+        }
+    }
+    return nil
+}
+
+// Import reads records written by Export and saves them, overwriting existing
+// keys. It stops at the first invalid record and reports its byte offset;
+// records before it stay imported.
+func (db *Database) Import(ctx context.Context, r io.Reader) error {
+    dec := json.NewDecoder(r)
+    for {
+        if err := ctx.Err(); err != nil {
+            return &OpError{Op: "import", Err: err}
+        }
+        offset := dec.InputOffset()
+        var rec exportRecord
+        err := dec.Decode(&rec)
+        if errors.Is(err, io.EOF) {
+            return nil
+        }
+        if err == nil && (rec.Key == nil || rec.Value == nil) {
+            err = errors.New("missing key or value")
+        }
+        if err != nil {
+            return &OpError{Op: "import", Err: fmt.Errorf("corrupt record at offset %d: %w", offset, err)}
+        }
+
+        var ttl time.Duration
+        if rec.ExpiresAt != nil {
+            if ttl = time.Until(*rec.ExpiresAt); ttl <= 0 {
+                continue
+            }
+        }
+        if _, err := db.save(ctx, *rec.Key, []byte(*rec.Value), ttl); err != nil {
+            return &OpError{Op: "import", Key: *rec.Key, Err: fmt.Errorf("record at offset %d: %w", offset, err)}
+        }
+    }
+}
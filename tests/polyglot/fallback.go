@@ -0,0 +1,279 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "slices"
+    "sync"
+)
+
+// FallbackStorage writes to secondary while primary fails. A save that fails
+// on primary with a transient error, that is one not marked Permanent and not
+// caused by the caller's context, is saved to secondary instead, and the key
+// is remembered as living there until Reconcile moves it back.
+//
+// Loads of remembered keys go to secondary; other loads try primary first and
+// fall back to secondary on ErrNotFound or a transient error.
+type FallbackStorage struct {
+    primary   Storage
+    secondary Storage
+
+    mu     sync.Mutex
+    fallen map[string]bool     // keys saved to secondary
+    locks  map[string]*keyLock // per-key locks in use, see lock
+}
+
+type keyLock struct {
+    sync.Mutex
+    refs int
+}
+
+var _ Storage = (*FallbackStorage)(nil)
+
+func NewFallbackStorage(primary, secondary Storage) *FallbackStorage {
+    return &FallbackStorage{
+        primary:   primary,
+        secondary: secondary,
+        fallen:    make(map[string]bool),
+        locks:     make(map[string]*keyLock),
+    }
+}
+
+// lock takes the per-key locks of keys, which writes hold so that Reconcile
+// never moves an old value over a newer one, and returns the func that
+// releases them. Keys are locked in sorted order, so batches cannot
+// deadlock.
+func (f *FallbackStorage) lock(keys ...string) (unlock func()) {
+    keys = slices.Compact(slices.Sorted(slices.Values(keys)))
+    held := make([]*keyLock, len(keys))
+    f.mu.Lock()
+    for i, k := range keys {
+        l, ok := f.locks[k]
+        if !ok {
+            l = &keyLock{}
+            f.locks[k] = l
+        }
+        l.refs++
+        held[i] = l
+    }
+    f.mu.Unlock()
+    for _, l := range held {
+        l.Lock()
+    }
+    return func() {
+        f.mu.Lock()
+        defer f.mu.Unlock()
+        for i, l := range held {
+            l.Unlock()
+            if l.refs--; l.refs == 0 {
+                delete(f.locks, keys[i])
+            }
+        }
+    }
+}
+
+func transient(ctx context.Context, err error) bool {
+    var perm *permanentError
+    return err != nil && ctx.Err() == nil && !errors.As(err, &perm)
+}
+
+func (f *FallbackStorage) isFallen(key string) bool {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    return f.fallen[key]
+}
+
+func (f *FallbackStorage) markFallen(keys ...string) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    for _, k := range keys {
+        f.fallen[k] = true
+    }
+}
+
+func (f *FallbackStorage) clearFallen(keys ...string) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    for _, k := range keys {
+        delete(f.fallen, k)
+    }
+}
+
+func (f *FallbackStorage) Save(key, data string) (uint64, error) {
+    return f.SaveContext(context.Background(), key, data)
+}
+
+func (f *FallbackStorage) SaveContext(ctx context.Context, key, data string) (uint64, error) {
+    defer f.lock(key)()
+    rev, err := saveContext(ctx, f.primary, key, data)
+    if err == nil {
+        f.clearFallen(key)
+        return rev, nil
+    }
+    if !transient(ctx, err) {
+        return 0, err
+    }
+    rev, serr := saveContext(ctx, f.secondary, key, data)
+    if serr != nil {
+        return 0, errors.Join(err, serr)
+    }
+    f.markFallen(key)
+    return rev, nil
+}
+
+func (f *FallbackStorage) Load(key string) (string, error) {
+    return f.LoadContext(context.Background(), key)
+}
+
+func (f *FallbackStorage) LoadContext(ctx context.Context, key string) (string, error) {
+    if f.isFallen(key) {
+        return loadContext(ctx, f.secondary, key)
+    }
+    data, err := loadContext(ctx, f.primary, key)
+    if err == nil || !(errors.Is(err, ErrNotFound) || transient(ctx, err)) {
+        return data, err
+    }
+    data, serr := loadContext(ctx, f.secondary, key)
+    if serr != nil && !errors.Is(err, ErrNotFound) {
+        return "", errors.Join(err, serr)
+    }
+    return data, serr
+}
+
+// SaveBatch saves items to primary and the items that failed there with a
+// transient error to secondary.
+func (f *FallbackStorage) SaveBatch(ctx context.Context, items map[string]string) error {
+    defer f.lock(sortedKeys(items)...)()
+    primaryErrs := batchErrors(f.primary.SaveBatch(ctx, items), items)
+    retry := make(map[string]string)
+    var failed []error
+    for k, err := range primaryErrs {
+        if transient(ctx, err) {
+            retry[k] = items[k]
+        } else {
+            failed = append(failed, &KeyError{Key: k, Err: err})
+        }
+    }
+    var saved []string
+    for k := range items {
+        if _, ok := primaryErrs[k]; !ok {
+            saved = append(saved, k)
+        }
+    }
+    f.clearFallen(saved...)
+    if len(retry) == 0 {
+        return batchError(failed, len(items))
+    }
+
+    secondaryErrs := batchErrors(f.secondary.SaveBatch(ctx, retry), retry)
+    var fallen []string
+    for k := range retry {
+        if err, ok := secondaryErrs[k]; ok {
+            failed = append(failed, &KeyError{Key: k, Err: errors.Join(primaryErrs[k], err)})
+        } else {
+            fallen = append(fallen, k)
+        }
+    }
+    f.markFallen(fallen...)
+    return batchError(failed, len(items))
+}
+
+func (f *FallbackStorage) LoadMany(ctx context.Context, keys []string) (map[string]string, error) {
+    return LoadEach(ctx, f, keys)
+}
+
+func (f *FallbackStorage) DeleteMany(ctx context.Context, keys []string) error {
+    return DeleteEach(ctx, f, keys)
+}
+
+// Delete removes key from both stores.
+func (f *FallbackStorage) Delete(key string) error {
+    defer f.lock(key)()
+    perr := f.primary.Delete(key)
+    serr := f.secondary.Delete(key)
+    if serr == nil {
+        f.clearFallen(key)
+    }
+    return errors.Join(perr, serr)
+}
+
+func (f *FallbackStorage) Has(ctx context.Context, key string) (bool, error) {
+    if f.isFallen(key) {
+        return f.secondary.Has(ctx, key)
+    }
+    ok, err := f.primary.Has(ctx, key)
+    if ok || (err != nil && !transient(ctx, err)) {
+        return ok, err
+    }
+    ok, serr := f.secondary.Has(ctx, key)
+    if serr != nil && err != nil {
+        return false, errors.Join(err, serr)
+    }
+    return ok, serr
+}
+
+// Ping succeeds while either store is reachable.
+func (f *FallbackStorage) Ping(ctx context.Context) error {
+    err := f.primary.Ping(ctx)
+    if err == nil {
+        return nil
+    }
+    if serr := f.secondary.Ping(ctx); serr != nil {
+        return errors.Join(err, serr)
+    }
+    return nil
+}
+
+func (f *FallbackStorage) Sync(ctx context.Context) error {
+    return errors.Join(f.primary.Sync(ctx), f.secondary.Sync(ctx))
+}
+
+// Reconcile moves the keys saved to secondary back to primary, removing them
+// from secondary. Saves and deletes of a key wait while it is moved. Keys
+// that fail stay on secondary for the next Reconcile; the failures are
+// returned as KeyErrors.
+func (f *FallbackStorage) Reconcile(ctx context.Context) error {
+    f.mu.Lock()
+    pending := make([]string, 0, len(f.fallen))
+    for k := range f.fallen {
+        pending = append(pending, k)
+    }
+    f.mu.Unlock()
+    slices.Sort(pending)
+
+    var failed []error
+    for _, k := range pending {
+        if err := ctx.Err(); err != nil {
+            return err
+        }
+        if err := f.reconcile(ctx, k); err != nil {
+            failed = append(failed, &KeyError{Key: k, Err: err})
+        }
+    }
+    if len(failed) > 0 {
+        return fmt.Errorf("reconcile: %d of %d keys failed: %w", len(failed), len(pending), errors.Join(failed...))
+    }
+    return nil
+}
+
+func (f *FallbackStorage) reconcile(ctx context.Context, key string) error {
+    defer f.lock(key)()
+    if !f.isFallen(key) {
+        // Saved to primary or deleted since Reconcile listed it.
+        return nil
+    }
+    data, err := loadContext(ctx, f.secondary, key)
+    if errors.Is(err, ErrNotFound) {
+        f.clearFallen(key)
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    if _, err := saveContext(ctx, f.primary, key, data); err != nil {
+        return err
+    }
+    f.clearFallen(key)
+    return f.secondary.Delete(key)
+}
@@ -0,0 +1,630 @@
+package main
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "hash/crc32"
+    "io"
+    "log/slog"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+)
+
+// fileRecord is one entry of the log. For opBlob records, Value is the name
+// of the file in the blob directory that holds the value.
+type fileRecord struct {
+    Op    byte
+    Key   string
+    Value string
+}
+
+const (
+    opPut    = 'p'
+    opDelete = 'd'
+    opBlob   = 'b'
+)
+
+// The file starts with fileMagic, the format version and the codec name.
+// Each record after it is a frame: a 4-byte big-endian length and a 4-byte
+// CRC-32C of the payload, then the payload of that length, holding the op
+// and the codec's encoding of key and value.
+const (
+    fileMagic       = "PGKV"
+    fileVersion     = 1
+    frameHeaderSize = 8
+    maxFrameSize    = 1 << 30
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+var (
+    errFrameLength   = errors.New("invalid record length")
+    errFrameChecksum = errors.New("record checksum mismatch")
+)
+
+type FileOption func(*FileStorage)
+
+// WithFsync makes every write fsync the file before it returns.
+func WithFsync(enabled bool) FileOption {
+    return func(fs *FileStorage) { fs.fsync = enabled }
+}
+
+// WithCodec sets the record encoding, GobCodec unless set. A file can only
+// be reopened with the codec it was created with.
+func WithCodec(c Codec) FileOption {
+    return func(fs *FileStorage) { fs.codec = c }
+}
+
+// FileStorage is a Storage persisted to a single append-only log file.
+// Values are kept in memory and replayed from the file on open, except those
+// written by SaveStream, which live in their own files in a blob directory
+// next to it. All access is serialized.
+type FileStorage struct {
+    path   string
+    fsync  bool
+    codec  Codec
+    logger *slog.Logger
+
+    mu     sync.Mutex
+    f      *os.File
+    data   map[string]string
+    blobs  map[string]string // key -> blob file name, for streamed values
+    closed bool
+}
+
+var _ Storage = (*FileStorage)(nil)
+
+// NewFileStorage opens or creates the file at path and loads its records.
+// It fails with ErrCodecMismatch if the file was written in another format.
+// Corrupt records are skipped with a warning. A truncated final record, or
+// a frame whose length cannot be trusted, ends the log: the rest of the file
+// is moved to a ".corrupt" file next to it and cut off, so later appends
+// start on a clean frame.
+func NewFileStorage(path string, opts ...FileOption) (*FileStorage, error) {
+    fs := &FileStorage{
+        path:   path,
+        codec:  GobCodec{},
+        logger: slog.Default(),
+        data:   make(map[string]string),
+        blobs:  make(map[string]string),
+    }
+    for _, opt := range opts {
+        opt(fs)
+    }
+    f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+    if err != nil {
+        return nil, fmt.Errorf("open file storage: %w", err)
+    }
+    if err := fs.replay(f); err != nil {
+        f.Close()
+        return nil, fmt.Errorf("open file storage %s: %w", path, err)
+    }
+    fs.f = f
+    return fs, nil
+}
+
+func (fs *FileStorage) header() []byte {
+    name := fs.codec.Name()
+    h := append([]byte(fileMagic), fileVersion, byte(len(name)))
+    return append(h, name...)
+}
+
+// readHeader checks the header of f, writing one if f is empty, and returns
+// its length.
+func (fs *FileStorage) readHeader(f *os.File, r *bufio.Reader) (int64, error) {
+    want := fs.header()
+    got := make([]byte, len(fileMagic)+2)
+    n, err := io.ReadFull(r, got)
+    if n == 0 && errors.Is(err, io.EOF) {
+        if _, err := f.Write(want); err != nil {
+            return 0, err
+        }
+        return int64(len(want)), nil
+    }
+    if err != nil || string(got[:len(fileMagic)]) != fileMagic {
+        return 0, fmt.Errorf("%w: not a file storage log", ErrCodecMismatch)
+    }
+    if v := got[len(fileMagic)]; v != fileVersion {
+        return 0, fmt.Errorf("%w: format version %d, want %d", ErrCodecMismatch, v, fileVersion)
+    }
+    name := make([]byte, got[len(fileMagic)+1])
+    if _, err := io.ReadFull(r, name); err != nil {
+        return 0, fmt.Errorf("%w: truncated header", ErrCodecMismatch)
+    }
+    if string(name) != fs.codec.Name() {
+        return 0, fmt.Errorf("%w: written with codec %q, opened with %q", ErrCodecMismatch, name, fs.codec.Name())
+    }
+    return int64(len(got) + len(name)), nil
+}
+
+func (fs *FileStorage) replay(f *os.File) error {
+    r := bufio.NewReader(f)
+    offset, err := fs.readHeader(f, r)
+    if err != nil {
+        return err
+    }
+    var header [frameHeaderSize]byte
+    for n := 1; ; n++ {
+        frame, err := readFrame(r, header[:])
+        if errors.Is(err, io.EOF) {
+            break
+        }
+        if err != nil && !errors.Is(err, errFrameChecksum) {
+            // Without a length to trust, nothing after offset can be framed.
+            fs.logger.Warn("file storage: dropping unreadable tail",
+                "path", fs.path, "record", n, "offset", offset, "error", err)
+            if err := fs.cutTail(f, offset); err != nil {
+                return err
+            }
+            break
+        }
+        if err == nil {
+            var rec fileRecord
+            if rec, err = fs.decode(frame); err == nil {
+                fs.apply(rec)
+            }
+        }
+        if err != nil {
+            fs.logger.Warn("file storage: skipping corrupt record",
+                "path", fs.path, "record", n, "offset", offset, "error", err)
+        }
+        offset += int64(len(header) + len(frame))
+    }
+    _, err = f.Seek(0, io.SeekEnd)
+    return err
+}
+
+// cutTail moves the bytes of f from offset on to a new ".corrupt" file next
+// to it and truncates f at offset.
+func (fs *FileStorage) cutTail(f *os.File, offset int64) error {
+    info, err := f.Stat()
+    if err != nil {
+        return err
+    }
+    tail, err := os.CreateTemp(filepath.Dir(fs.path), filepath.Base(fs.path)+".corrupt-*")
+    if err != nil {
+        return err
+    }
+    _, err = io.Copy(tail, io.NewSectionReader(f, offset, info.Size()-offset))
+    if err == nil {
+        err = tail.Sync()
+    }
+    if cerr := tail.Close(); err == nil {
+        err = cerr
+    }
+    if err != nil {
+        os.Remove(tail.Name())
+        return err
+    }
+    fs.logger.Warn("file storage: kept dropped tail", "path", tail.Name(), "bytes", info.Size()-offset)
+    return f.Truncate(offset)
+}
+
+// readFrame reads the next frame. It returns io.EOF at a clean end of file,
+// io.ErrUnexpectedEOF for a partial frame and errFrameLength for a length
+// out of range. A frame that fails its checksum is returned along with
+// errFrameChecksum, so the caller can skip it.
+func readFrame(r io.Reader, header []byte) ([]byte, error) {
+    if _, err := io.ReadFull(r, header); err != nil {
+        return nil, err
+    }
+    n := binary.BigEndian.Uint32(header)
+    if n == 0 || n > maxFrameSize {
+        return nil, fmt.Errorf("%w %d", errFrameLength, n)
+    }
+    frame := make([]byte, n)
+    if _, err := io.ReadFull(r, frame); err != nil {
+        if errors.Is(err, io.EOF) {
+            err = io.ErrUnexpectedEOF
+        }
+        return nil, err
+    }
+    if crc32.Checksum(frame, crcTable) != binary.BigEndian.Uint32(header[4:]) {
+        return frame, errFrameChecksum
+    }
+    return frame, nil
+}
+
+func (fs *FileStorage) decode(frame []byte) (fileRecord, error) {
+    rec := fileRecord{Op: frame[0]}
+    if rec.Op != opPut && rec.Op != opDelete && rec.Op != opBlob {
+        return rec, fmt.Errorf("unknown op %q", rec.Op)
+    }
+    var err error
+    rec.Key, rec.Value, err = fs.codec.Decode(bytes.NewReader(frame[1:]))
+    return rec, err
+}
+
+func (fs *FileStorage) apply(rec fileRecord) {
+    switch rec.Op {
+    case opPut:
+        fs.data[rec.Key] = rec.Value
+        delete(fs.blobs, rec.Key)
+    case opBlob:
+        delete(fs.data, rec.Key)
+        fs.blobs[rec.Key] = rec.Value
+    case opDelete:
+        delete(fs.data, rec.Key)
+        delete(fs.blobs, rec.Key)
+    }
+}
+
+// append writes recs in a single write call. Callers hold fs.mu.
+func (fs *FileStorage) append(recs ...fileRecord) error {
+    var buf bytes.Buffer
+    var frame bytes.Buffer
+    for _, rec := range recs {
+        frame.Reset()
+        frame.WriteByte(rec.Op)
+        if err := fs.codec.Encode(&frame, rec.Key, rec.Value); err != nil {
+            return err
+        }
+        var header [frameHeaderSize]byte
+        binary.BigEndian.PutUint32(header[:], uint32(frame.Len()))
+        binary.BigEndian.PutUint32(header[4:], crc32.Checksum(frame.Bytes(), crcTable))
+        buf.Write(header[:])
+        buf.Write(frame.Bytes())
+    }
+    if _, err := fs.f.Write(buf.Bytes()); err != nil {
+        return err
+    }
+    if fs.fsync {
+        return fs.f.Sync()
+    }
+    return nil
+}
+
+func (fs *FileStorage) Save(key, data string) (uint64, error) {
+    return fs.SaveContext(context.Background(), key, data)
+}
+
+func (fs *FileStorage) SaveContext(ctx context.Context, key, data string) (uint64, error) {
+    fs.mu.Lock()
+    defer fs.mu.Unlock()
+    if fs.closed {
+        return 0, ErrClosed
+    }
+    if err := ctx.Err(); err != nil {
+        return 0, err
+    }
+    if err := fs.append(fileRecord{Op: opPut, Key: key, Value: data}); err != nil {
+        return 0, fmt.Errorf("save %q: %w", key, err)
+    }
+    fs.data[key] = data
+    fs.dropBlob(key)
+    return 0, nil
+}
+
+func (fs *FileStorage) Load(key string) (string, error) {
+    fs.mu.Lock()
+    defer fs.mu.Unlock()
+    if fs.closed {
+        return "", ErrClosed
+    }
+    if name, ok := fs.blobs[key]; ok {
+        b, err := os.ReadFile(fs.blobPath(name))
+        if err != nil {
+            return "", fmt.Errorf("load %q: %w", key, err)
+        }
+        return string(b), nil
+    }
+    data, ok := fs.data[key]
+    if !ok {
+        return "", ErrNotFound
+    }
+    return data, nil
+}
+
+// SaveBatch appends every item in one write. If that write fails, every key
+// is reported as failed.
+func (fs *FileStorage) SaveBatch(ctx context.Context, items map[string]string) error {
+    fs.mu.Lock()
+    defer fs.mu.Unlock()
+    if fs.closed {
+        return ErrClosed
+    }
+    keys := sortedKeys(items)
+    fail := func(err error) error {
+        failed := make([]error, len(keys))
+        for i, k := range keys {
+            failed[i] = &KeyError{Key: k, Err: err}
+        }
+        return opError("save_batch", "", batchError(failed, len(items)))
+    }
+    if err := ctx.Err(); err != nil {
+        return fail(err)
+    }
+    recs := make([]fileRecord, len(keys))
+    for i, k := range keys {
+        recs[i] = fileRecord{Op: opPut, Key: k, Value: items[k]}
+    }
+    if err := fs.append(recs...); err != nil {
+        return fail(err)
+    }
+    for _, k := range keys {
+        fs.data[k] = items[k]
+        fs.dropBlob(k)
+    }
+    return nil
+}
+
+func (fs *FileStorage) Delete(key string) error {
+    fs.mu.Lock()
+    defer fs.mu.Unlock()
+    if fs.closed {
+        return ErrClosed
+    }
+    _, inline := fs.data[key]
+    if _, blob := fs.blobs[key]; !inline && !blob {
+        return nil
+    }
+    if err := fs.append(fileRecord{Op: opDelete, Key: key}); err != nil {
+        return fmt.Errorf("delete %q: %w", key, err)
+    }
+    delete(fs.data, key)
+    fs.dropBlob(key)
+    return nil
+}
+
+// LoadMany reads keys under one lock acquisition.
+func (fs *FileStorage) LoadMany(ctx context.Context, keys []string) (map[string]string, error) {
+    fs.mu.Lock()
+    defer fs.mu.Unlock()
+    if fs.closed {
+        return nil, ErrClosed
+    }
+    if err := ctx.Err(); err != nil {
+        return nil, err
+    }
+    found := make(map[string]string, len(keys))
+    for _, k := range keys {
+        if name, ok := fs.blobs[k]; ok {
+            b, err := os.ReadFile(fs.blobPath(name))
+            if err != nil {
+                return nil, fmt.Errorf("load %q: %w", k, err)
+            }
+            found[k] = string(b)
+        } else if data, ok := fs.data[k]; ok {
+            found[k] = data
+        }
+    }
+    return found, nil
+}
+
+// DeleteMany appends the deletes of every present key in one write.
+func (fs *FileStorage) DeleteMany(ctx context.Context, keys []string) error {
+    fs.mu.Lock()
+    defer fs.mu.Unlock()
+    if fs.closed {
+        return ErrClosed
+    }
+    if err := ctx.Err(); err != nil {
+        return err
+    }
+    var recs []fileRecord
+    seen := make(map[string]bool, len(keys))
+    for _, k := range keys {
+        _, inline := fs.data[k]
+        _, blob := fs.blobs[k]
+        if (inline || blob) && !seen[k] {
+            seen[k] = true
+            recs = append(recs, fileRecord{Op: opDelete, Key: k})
+        }
+    }
+    if len(recs) == 0 {
+        return nil
+    }
+    if err := fs.append(recs...); err != nil {
+        return fmt.Errorf("delete many: %w", err)
+    }
+    for _, rec := range recs {
+        delete(fs.data, rec.Key)
+        fs.dropBlob(rec.Key)
+    }
+    return nil
+}
+
+func (fs *FileStorage) Has(ctx context.Context, key string) (bool, error) {
+    fs.mu.Lock()
+    defer fs.mu.Unlock()
+    if fs.closed {
+        return false, ErrClosed
+    }
+    if err := ctx.Err(); err != nil {
+        return false, err
+    }
+    _, inline := fs.data[key]
+    _, blob := fs.blobs[key]
+    return inline || blob, nil
+}
+
+func (fs *FileStorage) Ping(ctx context.Context) error {
+    fs.mu.Lock()
+    defer fs.mu.Unlock()
+    if fs.closed {
+        return ErrClosed
+    }
+    return ctx.Err()
+}
+
+// Keys returns the stored keys in sorted order.
+func (fs *FileStorage) Keys() []string {
+    fs.mu.Lock()
+    defer fs.mu.Unlock()
+    keys := make([]string, 0, len(fs.data)+len(fs.blobs))
+    for k := range fs.data {
+        keys = append(keys, k)
+    }
+    for k := range fs.blobs {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    return keys
+}
+
+// Sync fsyncs the log file and the blob directory, so every write that has
+// returned survives a crash.
+func (fs *FileStorage) Sync(ctx context.Context) error {
+    fs.mu.Lock()
+    defer fs.mu.Unlock()
+    if fs.closed {
+        return ErrClosed
+    }
+    if err := ctx.Err(); err != nil {
+        return err
+    }
+    return fs.sync()
+}
+
+func (fs *FileStorage) sync() error {
+    if err := fs.f.Sync(); err != nil {
+        return fmt.Errorf("sync %s: %w", fs.path, err)
+    }
+    return fs.syncBlobDir()
+}
+
+// syncBlobDir fsyncs the blob directory, if there is one, so that the blob
+// files renamed into it survive a crash.
+func (fs *FileStorage) syncBlobDir() error {
+    dir, err := os.Open(fs.blobDir())
+    if errors.Is(err, os.ErrNotExist) {
+        return nil
+    }
+    if err != nil {
+        return fmt.Errorf("sync %s: %w", fs.blobDir(), err)
+    }
+    defer dir.Close()
+    if err := dir.Sync(); err != nil {
+        return fmt.Errorf("sync %s: %w", fs.blobDir(), err)
+    }
+    return nil
+}
+
+// Close syncs and closes the file. The file is closed even if the sync fails.
+func (fs *FileStorage) Close() error {
+    fs.mu.Lock()
+    defer fs.mu.Unlock()
+    if fs.closed {
+        return nil
+    }
+    fs.closed = true
+    fs.data = nil
+    fs.blobs = nil
+    err := fs.sync()
+    if cerr := fs.f.Close(); err == nil {
+        err = cerr
+    }
+    return err
+}
+
+func (fs *FileStorage) blobDir() string {
+    return fs.path + ".blobs"
+}
+
+func (fs *FileStorage) blobPath(name string) string {
+    return filepath.Join(fs.blobDir(), name)
+}
+
+// dropBlob forgets the blob file holding key's previous value, if any, and
+// removes it. Callers hold fs.mu.
+func (fs *FileStorage) dropBlob(key string) {
+    name, ok := fs.blobs[key]
+    if !ok {
+        return
+    }
+    delete(fs.blobs, key)
+    if err := os.Remove(fs.blobPath(name)); err != nil {
+        fs.logger.Warn("file storage: removing stale blob", "path", fs.blobPath(name), "error", err)
+    }
+}
+
+// SaveStream copies r into a temporary file in the blob directory, renames it
+// into place once r is fully read, and only then records it under key. A
+// failed or cancelled read leaves the previous value untouched.
+func (fs *FileStorage) SaveStream(ctx context.Context, key string, r io.Reader) (int64, error) {
+    if err := os.MkdirAll(fs.blobDir(), 0o755); err != nil {
+        return 0, fmt.Errorf("save stream %q: %w", key, err)
+    }
+    tmp, err := os.CreateTemp(fs.blobDir(), "tmp-*")
+    if err != nil {
+        return 0, fmt.Errorf("save stream %q: %w", key, err)
+    }
+    n, err := io.Copy(tmp, ctxReader{ctx: ctx, r: r})
+    if err == nil && fs.fsync {
+        err = tmp.Sync()
+    }
+    if cerr := tmp.Close(); err == nil {
+        err = cerr
+    }
+    name := "blob-" + strings.TrimPrefix(filepath.Base(tmp.Name()), "tmp-")
+    if err == nil {
+        err = os.Rename(tmp.Name(), fs.blobPath(name))
+    }
+    if err != nil {
+        os.Remove(tmp.Name())
+        return 0, fmt.Errorf("save stream %q: %w", key, err)
+    }
+    // The rename must be durable before the log can point at the blob.
+    if fs.fsync {
+        if err := fs.syncBlobDir(); err != nil {
+            os.Remove(fs.blobPath(name))
+            return 0, fmt.Errorf("save stream %q: %w", key, err)
+        }
+    }
+
+    fs.mu.Lock()
+    defer fs.mu.Unlock()
+    if err := fs.commitBlob(ctx, key, name); err != nil {
+        os.Remove(fs.blobPath(name))
+        return 0, fmt.Errorf("save stream %q: %w", key, err)
+    }
+    return n, nil
+}
+
+func (fs *FileStorage) commitBlob(ctx context.Context, key, name string) error {
+    if fs.closed {
+        return ErrClosed
+    }
+    if err := ctx.Err(); err != nil {
+        return err
+    }
+    if err := fs.append(fileRecord{Op: opBlob, Key: key, Value: name}); err != nil {
+        return err
+    }
+    delete(fs.data, key)
+    fs.dropBlob(key)
+    fs.blobs[key] = name
+    return nil
+}
+
+// LoadStream returns a reader over the value stored under key. Streamed
+// values are read straight from their blob file.
+func (fs *FileStorage) LoadStream(ctx context.Context, key string) (io.ReadCloser, error) {
+    fs.mu.Lock()
+    defer fs.mu.Unlock()
+    if fs.closed {
+        return nil, ErrClosed
+    }
+    if err := ctx.Err(); err != nil {
+        return nil, err
+    }
+    if name, ok := fs.blobs[key]; ok {
+        f, err := os.Open(fs.blobPath(name))
+        if err != nil {
+            return nil, fmt.Errorf("load stream %q: %w", key, err)
+        }
+        return f, nil
+    }
+    data, ok := fs.data[key]
+    if !ok {
+        return nil, ErrNotFound
+    }
+    return io.NopCloser(strings.NewReader(data)), nil
+}
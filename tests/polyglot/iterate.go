@@ -0,0 +1,38 @@
+package main
+
+import (
+    "context"
+    "time"
+)
+
+// Iterate calls fn for every live entry, stopping at the first error from fn
+// or when ctx is done. It walks a snapshot taken under the read lock and
+// calls fn without holding any lock, so fn may use db freely. Writes made
+// while Iterate runs may or may not be seen.
+func (db *Database) Iterate(ctx context.Context, fn func(key, value string) error) error {
+    type kv struct{ key, value string }
+
+    db.mu.RLock()
+    if db.closed {
+        db.mu.RUnlock()
+        return &OpError{Op: "iterate", Err: ErrClosed}
+    }
+    now := time.Now()
+    snapshot := make([]kv, 0, len(db.data))
+    for k, e := range db.data {
+        if !e.expired(now) {
+            snapshot = append(snapshot, kv{k, string(e.value)})
+        }
+    }
+    db.mu.RUnlock()
+
+    for _, p := range snapshot {
+        if err := ctx.Err(); err != nil {
+            return &OpError{Op: "iterate", Err: err}
+        }
+        if err := fn(p.key, p.value); err != nil {
+            return err
+        }
+    }
+    return nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+)
+
+// SaveJSON stores the JSON encoding of v under key.
+func SaveJSON[T any](ctx context.Context, s Storage, key string, v T) error {
+    data, err := json.Marshal(v)
+    if err != nil {
+        return fmt.Errorf("save json %q: %w", key, err)
+    }
+    _, err = saveContext(ctx, s, key, string(data))
+    return err
+}
+
+// LoadJSON decodes the JSON value stored under key. Errors from the Storage,
+// including ErrNotFound, are returned unchanged; decode errors name the key.
+func LoadJSON[T any](ctx context.Context, s Storage, key string) (T, error) {
+    var v T
+    data, err := loadContext(ctx, s, key)
+    if err != nil {
+        return v, err
+    }
+    if err := json.Unmarshal([]byte(data), &v); err != nil {
+        var zero T
+        return zero, fmt.Errorf("load json %q: %w", key, err)
+    }
+    return v, nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+)
+
+// key returns the form of k that is stored: k after the KeyNormalizer, if
+// one is set. It fails with ErrInvalidKey if the KeyValidator rejects that
+// form, or, without a KeyValidator, if it is empty.
+func (db *Database) key(k string) (string, error) {
+    if db.opts.KeyNormalizer != nil {
+        k = db.opts.KeyNormalizer(k)
+    }
+    if db.opts.KeyValidator == nil {
+        if k == "" {
+            return "", ErrInvalidKey
+        }
+        return k, nil
+    }
+    if err := db.opts.KeyValidator(k); err != nil {
+        if errors.Is(err, ErrInvalidKey) {
+            return "", err
+        }
+        return "", fmt.Errorf("%w: %w", ErrInvalidKey, err)
+    }
+    return k, nil
+}
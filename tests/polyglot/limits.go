@@ -0,0 +1,38 @@
+package main
+
+import (
+    "fmt"
+    "time"
+)
+
+// checkPut applies MaxValueSize and MaxKeys to saving size bytes under key.
+// Callers hold db.mu.
+func (db *Database) checkPut(key string, size int) error {
+    if err := db.checkValue(size); err != nil {
+        return err
+    }
+    if e, ok := db.data[key]; ok && !e.expired(time.Now()) {
+        return nil
+    }
+    return db.checkQuota(1)
+}
+
+func (db *Database) checkValue(size int) error {
+    if db.maxValue > 0 && size > db.maxValue {
+        return fmt.Errorf("%w: %d bytes, limit is %d", ErrValueTooLarge, size, db.maxValue)
+    }
+    return nil
+}
+
+// checkQuota reports whether added more keys fit under MaxKeys. Expired
+// entries are purged before a save is refused so they do not count.
+func (db *Database) checkQuota(added int) error {
+    if db.maxKeys <= 0 || added <= 0 || len(db.data)+added <= db.maxKeys {
+        return nil
+    }
+    db.purgeExpired(time.Now())
+    if len(db.data)+added > db.maxKeys {
+        return fmt.Errorf("%w: limit is %d keys", ErrQuotaExceeded, db.maxKeys)
+    }
+    return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+    "context"
+    "log/slog"
+    "time"
+)
+
+// LoggingStorage logs every operation on the wrapped Storage at debug level.
+type LoggingStorage struct {
+    inner  Storage
+    logger *slog.Logger
+}
+
+var _ Storage = (*LoggingStorage)(nil)
+
+// NewLoggingStorage logs the operations on s to logger. A nil logger
+// discards them, as ConnectOptions.Logger does.
+func NewLoggingStorage(s Storage, logger *slog.Logger) *LoggingStorage {
+    if logger == nil {
+        logger = slog.New(slog.DiscardHandler)
+    }
+    return &LoggingStorage{inner: s, logger: logger}
+}
+
+func (l *LoggingStorage) Save(key, data string) (uint64, error) {
+    return l.SaveContext(context.Background(), key, data)
+}
+
+func (l *LoggingStorage) SaveContext(ctx context.Context, key, data string) (uint64, error) {
+    start := time.Now()
+    rev, err := saveContext(ctx, l.inner, key, data)
+    l.log(ctx, "save", key, start, err)
+    return rev, err
+}
+
+func (l *LoggingStorage) Load(key string) (string, error) {
+    return l.LoadContext(context.Background(), key)
+}
+
+func (l *LoggingStorage) LoadContext(ctx context.Context, key string) (string, error) {
+    start := time.Now()
+    data, err := loadContext(ctx, l.inner, key)
+    l.log(ctx, "load", key, start, err)
+    return data, err
+}
+
+func (l *LoggingStorage) SaveBatch(ctx context.Context, items map[string]string) error {
+    start := time.Now()
+    err := l.inner.SaveBatch(ctx, items)
+    l.log(ctx, "save_batch", "", start, err, slog.Int("keys", len(items)))
+    return err
+}
+
+func (l *LoggingStorage) Delete(key string) error {
+    start := time.Now()
+    err := l.inner.Delete(key)
+    l.log(context.Background(), "delete", key, start, err)
+    return err
+}
+
+func (l *LoggingStorage) LoadMany(ctx context.Context, keys []string) (map[string]string, error) {
+    start := time.Now()
+    found, err := l.inner.LoadMany(ctx, keys)
+    l.log(ctx, "load_many", "", start, err, slog.Int("keys", len(keys)), slog.Int("found", len(found)))
+    return found, err
+}
+
+func (l *LoggingStorage) DeleteMany(ctx context.Context, keys []string) error {
+    start := time.Now()
+    err := l.inner.DeleteMany(ctx, keys)
+    l.log(ctx, "delete_many", "", start, err, slog.Int("keys", len(keys)))
+    return err
+}
+
+func (l *LoggingStorage) Has(ctx context.Context, key string) (bool, error) {
+    start := time.Now()
+    ok, err := l.inner.Has(ctx, key)
+    l.log(ctx, "has", key, start, err)
+    return ok, err
+}
+
+func (l *LoggingStorage) Sync(ctx context.Context) error {
+    start := time.Now()
+    err := l.inner.Sync(ctx)
+    l.log(ctx, "sync", "", start, err)
+    return err
+}
+
+func (l *LoggingStorage) Ping(ctx context.Context) error {
+    return l.inner.Ping(ctx)
+}
+
+func (l *LoggingStorage) log(ctx context.Context, op, key string, start time.Time, err error, extra ...slog.Attr) {
+    if !l.logger.Enabled(ctx, slog.LevelDebug) {
+        return
+    }
+    attrs := append([]slog.Attr{
+        slog.String("op", op),
+        slog.String("key", key),
+        slog.Duration("duration", time.Since(start)),
+    }, extra...)
+    if id := CorrelationID(ctx); id != "" {
+        attrs = append(attrs, slog.String("correlation_id", id))
+    }
+    if err != nil {
+        attrs = append(attrs, slog.Any("error", err))
+    }
+    l.logger.LogAttrs(ctx, slog.LevelDebug, "storage op", attrs...)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+    "container/list"
+    "context"
+    "log/slog"
+    "time"
+)
+
+// Resize changes LRUEntries to n, evicting the least recently used keys if
+// there are more than n. A positive n on a Database without an LRU starts
+// one, with the existing keys in no particular order; zero or less removes
+// the bound.
+func (db *Database) Resize(n int) {
+    defer db.reportEvictions()
+    db.mu.Lock()
+    defer db.mu.Unlock()
+    if db.closed {
+        return
+    }
+    db.opts.LRUEntries = n
+    if n <= 0 {
+        db.lru = nil
+        return
+    }
+    if db.lru == nil {
+        db.relink(nil)
+    }
+    db.evict()
+}
+
+// relink builds db.lru afresh: keys found in order come first, in that
+// order, and the rest after them. Callers hold db.mu.
+func (db *Database) relink(order *list.List) {
+    db.lru = list.New()
+    for key, e := range db.data {
+        e.elem = nil
+        db.data[key] = e
+    }
+    if order != nil {
+        for el := order.Front(); el != nil; el = el.Next() {
+            key := el.Value.(string)
+            if e, ok := db.data[key]; ok && e.elem == nil {
+                e.elem = db.lru.PushBack(key)
+                db.data[key] = e
+            }
+        }
+    }
+    for key, e := range db.data {
+        if e.elem == nil {
+            e.elem = db.lru.PushBack(key)
+            db.data[key] = e
+        }
+    }
+}
+
+// touch marks e as just used. Callers hold db.mu, for reading at least.
+func (db *Database) touch(e entry) {
+    if db.lru == nil || e.elem == nil {
+        return
+    }
+    db.lruMu.Lock()
+    db.lru.MoveToFront(e.elem)
+    db.lruMu.Unlock()
+}
+
+// unlink removes key, whose entry is e. Callers hold db.mu.
+func (db *Database) unlink(key string, e entry) {
+    if db.lru != nil && e.elem != nil {
+        db.lru.Remove(e.elem)
+    }
+    delete(db.data, key)
+    db.tombs[key] = e.rev
+}
+
+// evict removes least recently used keys until LRUEntries is respected,
+// publishing an EventEvict for each and queueing it for reportEvictions.
+// Callers hold db.mu.
+func (db *Database) evict() {
+    if db.lru == nil {
+        return
+    }
+    for db.lru.Len() > db.opts.LRUEntries {
+        key := db.lru.Back().Value.(string)
+        db.unlink(key, db.data[key])
+        db.evictions.Add(1)
+        if len(db.subs) > 0 {
+            db.publish(Event{Type: EventEvict, Key: key})
+        }
+        if db.observer != nil || db.logger.Enabled(context.Background(), slog.LevelDebug) {
+            db.evictMu.Lock()
+            db.evicted = append(db.evicted, key)
+            db.hasEvicted.Store(true)
+            db.evictMu.Unlock()
+        }
+    }
+}
+
+// reportEvictions records an "evict" operation for every key evicted since
+// the last call. Operations call it once they have released db.mu, so that a
+// slow Observer or logger does not hold up the Database.
+func (db *Database) reportEvictions() {
+    if !db.hasEvicted.Swap(false) {
+        return
+    }
+    db.evictMu.Lock()
+    keys := db.evicted
+    db.evicted = nil
+    db.evictMu.Unlock()
+    for _, key := range keys {
+        db.record(context.Background(), "evict", key, time.Now(), nil)
+    }
+}
+
+// DatabaseStats counts the outcomes of reads and the LRU evictions of a
+// Database since it was opened.
+type DatabaseStats struct {
+    Hits      uint64 // loads, including Has, that found a live value
+    Misses    uint64 // loads that found none
+    Evictions uint64
+}
+
+func (db *Database) Stats() DatabaseStats {
+    return DatabaseStats{
+        Hits:      db.hits.Load(),
+        Misses:    db.misses.Load(),
+        Evictions: db.evictions.Load(),
+    }
+}
+
+func (db *Database) countLoad(hit bool) {
+    if hit {
+        db.hits.Add(1)
+    } else {
+        db.misses.Add(1)
+    }
+}
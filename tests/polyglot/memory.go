@@ -0,0 +1,139 @@
+package main
+
+import (
+    "context"
+    "sort"
+    "sync"
+)
+
+var _ Storage = (*MemoryStorage)(nil)
+
+// MemoryStorage is a map-backed Storage for tests. It is safe for concurrent
+// use.
+type MemoryStorage struct {
+    mu   sync.RWMutex
+    data map[string]string
+}
+
+func NewMemoryStorage() *MemoryStorage {
+    return &MemoryStorage{data: make(map[string]string)}
+}
+
+// Save stores data under key. MemoryStorage does not track revisions, so the
+// revision is always 0.
+func (m *MemoryStorage) Save(key, data string) (uint64, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    m.data[key] = data
+    return 0, nil
+}
+
+func (m *MemoryStorage) SaveBatch(ctx context.Context, items map[string]string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    var failed []error
+    for _, k := range sortedKeys(items) {
+        if err := ctx.Err(); err != nil {
+            failed = append(failed, &KeyError{Key: k, Err: err})
+            continue
+        }
+        m.data[k] = items[k]
+    }
+    return opError("save_batch", "", batchError(failed, len(items)))
+}
+
+func (m *MemoryStorage) Load(key string) (string, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    data, ok := m.data[key]
+    if !ok {
+        return "", ErrNotFound
+    }
+    return data, nil
+}
+
+// Delete removes key. Deleting a missing key is not an error.
+func (m *MemoryStorage) Delete(key string) error {
+    m.remove(key)
+    return nil
+}
+
+// DeleteExisting removes key, returning ErrNotFound if it was not present.
+func (m *MemoryStorage) DeleteExisting(key string) error {
+    if !m.remove(key) {
+        return ErrNotFound
+    }
+    return nil
+}
+
+func (m *MemoryStorage) remove(key string) bool {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    _, found := m.data[key]
+    delete(m.data, key)
+    return found
+}
+
+func (m *MemoryStorage) LoadMany(ctx context.Context, keys []string) (map[string]string, error) {
+    if err := ctx.Err(); err != nil {
+        return nil, err
+    }
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    found := make(map[string]string, len(keys))
+    for _, k := range keys {
+        if data, ok := m.data[k]; ok {
+            found[k] = data
+        }
+    }
+    return found, nil
+}
+
+func (m *MemoryStorage) DeleteMany(ctx context.Context, keys []string) error {
+    if err := ctx.Err(); err != nil {
+        return err
+    }
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    for _, k := range keys {
+        delete(m.data, k)
+    }
+    return nil
+}
+
+func (m *MemoryStorage) Has(ctx context.Context, key string) (bool, error) {
+    if err := ctx.Err(); err != nil {
+        return false, err
+    }
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    _, ok := m.data[key]
+    return ok, nil
+}
+
+// Sync does nothing: MemoryStorage is not durable.
+func (m *MemoryStorage) Sync(ctx context.Context) error {
+    return ctx.Err()
+}
+
+func (m *MemoryStorage) Ping(ctx context.Context) error {
+    return ctx.Err()
+}
+
+func (m *MemoryStorage) Len() int {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    return len(m.data)
+}
+
+// Keys returns the saved keys in sorted order.
+func (m *MemoryStorage) Keys() []string {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    keys := make([]string, 0, len(m.data))
+    for k := range m.data {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    return keys
+}
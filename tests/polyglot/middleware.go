@@ -0,0 +1,28 @@
+package main
+
+import "log/slog"
+
+// Middleware wraps a Storage with extra behaviour.
+type Middleware func(Storage) Storage
+
+// Chain wraps base with mws so that the first middleware is the outermost:
+// Chain(s, a, b) is a(b(s)), and a call passes through a, then b, then s.
+func Chain(base Storage, mws ...Middleware) Storage {
+    s := base
+    for i := len(mws) - 1; i >= 0; i-- {
+        s = mws[i](s)
+    }
+    return s
+}
+
+func WithRetry(opts RetryOptions) Middleware {
+    return func(s Storage) Storage { return NewRetryStorage(s, opts) }
+}
+
+func WithLogging(logger *slog.Logger) Middleware {
+    return func(s Storage) Storage { return NewLoggingStorage(s, logger) }
+}
+
+func WithNamespace(prefix string) Middleware {
+    return func(s Storage) Storage { return NewPrefixStorage(s, prefix) }
+}
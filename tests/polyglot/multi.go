@@ -0,0 +1,128 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "time"
+)
+
+// LoadEach implements LoadMany with one load per distinct key, for backends
+// that have no batch read. Missing keys are left out of the result; any
+// other error stops it.
+func LoadEach(ctx context.Context, s Storage, keys []string) (map[string]string, error) {
+    found := make(map[string]string, len(keys))
+    seen := make(map[string]bool, len(keys))
+    for _, k := range keys {
+        if seen[k] {
+            continue
+        }
+        seen[k] = true
+        data, err := loadContext(ctx, s, k)
+        if errors.Is(err, ErrNotFound) {
+            continue
+        }
+        if err != nil {
+            return nil, &KeyError{Key: k, Err: err}
+        }
+        found[k] = data
+    }
+    return found, nil
+}
+
+// DeleteEach implements DeleteMany with one Delete per distinct key, for
+// backends that have no batch delete. It tries every key and reports the
+// ones that failed as KeyErrors.
+func DeleteEach(ctx context.Context, s Storage, keys []string) error {
+    seen := make(map[string]bool, len(keys))
+    var failed []error
+    for _, k := range keys {
+        if seen[k] {
+            continue
+        }
+        seen[k] = true
+        err := ctx.Err()
+        if err == nil {
+            err = s.Delete(k)
+        }
+        if err != nil {
+            failed = append(failed, &KeyError{Key: k, Err: err})
+        }
+    }
+    return deleteError(failed, len(seen))
+}
+
+// deleteError joins the per-key failures of a multi-key delete.
+func deleteError(failed []error, total int) error {
+    if len(failed) == 0 {
+        return nil
+    }
+    return fmt.Errorf("%d of %d keys failed to delete, the others were deleted: %w",
+        len(failed), total, errors.Join(failed...))
+}
+
+// LoadMany returns the live values of keys under one read lock. Keys without
+// a live value are left out of the result, which is keyed as keys is.
+func (db *Database) LoadMany(ctx context.Context, keys []string) (map[string]string, error) {
+    var found map[string]string
+    err := db.run(ctx, "load_many", "", func(ctx context.Context) error {
+        stored := make(map[string]string, len(keys))
+        for _, k := range keys {
+            key, err := db.key(k)
+            if err != nil {
+                return &KeyError{Key: k, Err: err}
+            }
+            stored[k] = key
+        }
+
+        db.mu.RLock()
+        defer db.mu.RUnlock()
+        if db.closed {
+            return ErrClosed
+        }
+        if err := ctx.Err(); err != nil {
+            return err
+        }
+        now := time.Now()
+        found = make(map[string]string, len(stored))
+        for k, key := range stored {
+            if e, ok := db.data[key]; ok && !e.expired(now) {
+                db.touch(e)
+                found[k] = string(e.value)
+            }
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    return found, nil
+}
+
+// DeleteMany removes keys under one write lock. Missing keys are not an
+// error. If any key is invalid nothing is deleted.
+func (db *Database) DeleteMany(ctx context.Context, keys []string) error {
+    return db.run(ctx, "delete_many", "", func(ctx context.Context) error {
+        stored := make([]string, len(keys))
+        for i, k := range keys {
+            key, err := db.key(k)
+            if err != nil {
+                return &KeyError{Key: k, Err: err}
+            }
+            stored[i] = key
+        }
+
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        if db.closed {
+            return ErrClosed
+        }
+        if err := ctx.Err(); err != nil {
+            return err
+        }
+        for _, key := range stored {
+            db.del(key)
+        }
+        return nil
+    })
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+    "context"
+    "log/slog"
+    "time"
+)
+
+// Observer is told about every Save, Load and Delete once it completes,
+// including the ones that fail, and about every LRU eviction as op "evict".
+// Implementations must be safe for concurrent use.
+type Observer interface {
+    ObserveOp(op string, key string, dur time.Duration, err error)
+}
+
+// run performs one operation: it waits for an in-flight slot, calls fn and
+// records the outcome. Failures are returned as an OpError for op and key.
+func (db *Database) run(ctx context.Context, op, key string, fn func(context.Context) error) error {
+    ctx, cancel := db.opContext(ctx)
+    defer cancel()
+    var span Span
+    if db.opts.Tracer != nil {
+        ctx, span = db.opts.Tracer.Start(ctx, spanName(op),
+            slog.String("storage.key", key), slog.String("storage.backend", "mem"))
+    }
+    start := time.Now()
+    err := db.acquire(ctx)
+    if err == nil {
+        err = fn(ctx)
+        db.release()
+        db.reportEvictions()
+    }
+    err = opError(op, key, err)
+    db.record(ctx, op, key, start, err)
+    if span != nil {
+        span.End(err)
+    }
+    return err
+}
+
+// opContext bounds ctx by OpTimeout, if one is set.
+func (db *Database) opContext(ctx context.Context) (context.Context, context.CancelFunc) {
+    if db.opTimeout <= 0 {
+        return ctx, func() {}
+    }
+    return context.WithTimeout(ctx, db.opTimeout)
+}
+
+func (db *Database) acquire(ctx context.Context) error {
+    if db.conns == nil {
+        return nil
+    }
+    select {
+    case db.conns <- struct{}{}:
+        return nil
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+}
+
+func (db *Database) release() {
+    if db.conns != nil {
+        <-db.conns
+    }
+}
+
+// record reports a finished operation to the configured logger and observer.
+func (db *Database) record(ctx context.Context, op, key string, start time.Time, err error) {
+    dur := time.Since(start)
+    if co, ok := db.observer.(ContextObserver); ok {
+        co.ObserveOpContext(ctx, op, key, dur, err)
+    } else if db.observer != nil {
+        db.observer.ObserveOp(op, key, dur, err)
+    }
+    if !db.logger.Enabled(ctx, slog.LevelDebug) {
+        return
+    }
+    attrs := []slog.Attr{
+        slog.String("op", op),
+        slog.String("key", key),
+        slog.String("uri", db.URI),
+        slog.Duration("duration", dur),
+    }
+    if id := CorrelationID(ctx); id != "" {
+        attrs = append(attrs, slog.String("correlation_id", id))
+    }
+    if err != nil {
+        attrs = append(attrs, slog.Any("error", err))
+    }
+    db.logger.LogAttrs(ctx, slog.LevelDebug, "storage op", attrs...)
+}
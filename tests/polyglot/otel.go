@@ -0,0 +1,51 @@
+//go:build otel
+
+package main
+
+import (
+    "context"
+    "log/slog"
+
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/codes"
+    "go.opentelemetry.io/otel/trace"
+)
+
+func init() {
+    traceID = func(ctx context.Context) string {
+        if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+            return sc.TraceID().String()
+        }
+        return ""
+    }
+}
+
+// NewOTelTracer adapts an OpenTelemetry tracer for WithTracer.
+func NewOTelTracer(t trace.Tracer) Tracer {
+    return otelTracer{t: t}
+}
+
+type otelTracer struct {
+    t trace.Tracer
+}
+
+func (o otelTracer) Start(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, Span) {
+    kvs := make([]attribute.KeyValue, len(attrs))
+    for i, a := range attrs {
+        kvs[i] = attribute.String(a.Key, a.Value.String())
+    }
+    ctx, span := o.t.Start(ctx, name, trace.WithAttributes(kvs...))
+    return ctx, otelSpan{span: span}
+}
+
+type otelSpan struct {
+    span trace.Span
+}
+
+func (s otelSpan) End(err error) {
+    if err != nil {
+        s.span.RecordError(err)
+        s.span.SetStatus(codes.Error, err.Error())
+    }
+    s.span.End()
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+    "container/list"
+    "context"
+    "errors"
+    "fmt"
+    "sync"
+)
+
+type PoolOptions struct {
+    // PingOnAcquire pings recycled connections before handing them out.
+    PingOnAcquire bool
+}
+
+type PoolStats struct {
+    InUse   int
+    Idle    int
+    Waiting int // callers blocked in Acquire
+}
+
+// Pool hands out up to max connections to the same URI, opening them lazily.
+// Callers blocked in Acquire are served in the order they arrived.
+type Pool struct {
+    uri  string
+    max  int
+    opts PoolOptions
+
+    mu      sync.Mutex
+    open    int
+    inUse   int
+    closed  bool
+    idle    []*Database
+    waiters list.List // of chan *Database, oldest first
+}
+
+func NewPool(uri string, max int) (*Pool, error) {
+    return NewPoolWithOptions(uri, max, PoolOptions{})
+}
+
+func NewPoolWithOptions(uri string, max int, opts PoolOptions) (*Pool, error) {
+    if max <= 0 {
+        return nil, errors.New("pool: max must be positive")
+    }
+    if err := validateURI(uri); err != nil {
+        return nil, fmt.Errorf("pool %q: %w", uri, err)
+    }
+    return &Pool{uri: uri, max: max, opts: opts}, nil
+}
+
+// Acquire returns an idle connection, opens a new one if the pool is below
+// max, or blocks until one is released or ctx is done. Released connections
+// go to the longest waiting caller first. With PingOnAcquire, recycled
+// connections that fail Ping are discarded and replaced.
+func (p *Pool) Acquire(ctx context.Context) (*Database, error) {
+    for {
+        p.mu.Lock()
+        if p.closed {
+            p.mu.Unlock()
+            return nil, ErrClosed
+        }
+        var db *Database
+        if n := len(p.idle); n > 0 {
+            db = p.idle[n-1]
+            p.idle = p.idle[:n-1]
+            p.mu.Unlock()
+        } else if p.open < p.max {
+            p.open++
+            p.inUse++
+            p.mu.Unlock()
+            return p.dial(ctx)
+        } else {
+            ch := make(chan *Database, 1)
+            elem := p.waiters.PushBack(ch)
+            p.mu.Unlock()
+            var err error
+            if db, err = p.wait(ctx, ch, elem); err != nil {
+                return nil, err
+            }
+            if db == nil {
+                // Granted the capacity of a connection that went away.
+                return p.dial(ctx)
+            }
+        }
+
+        if p.opts.PingOnAcquire {
+            if err := db.Ping(ctx); err != nil {
+                if ctx.Err() != nil {
+                    p.mu.Lock()
+                    p.put(db)
+                    p.mu.Unlock()
+                    return nil, ctx.Err()
+                }
+                p.discard(db)
+                continue
+            }
+        }
+        return p.checkout(db)
+    }
+}
+
+// wait blocks until put hands a connection to the queued waiter ch, or grant
+// hands it nil to dial one itself. A caller whose ctx ends leaves the queue;
+// a connection or grant handed to it at that moment is passed on to the next
+// waiter.
+func (p *Pool) wait(ctx context.Context, ch chan *Database, elem *list.Element) (*Database, error) {
+    select {
+    case db, ok := <-ch:
+        if !ok {
+            return nil, ErrClosed
+        }
+        return db, nil
+    case <-ctx.Done():
+        p.mu.Lock()
+        defer p.mu.Unlock()
+        select {
+        case db, ok := <-ch:
+            if ok && db != nil {
+                p.put(db)
+            } else if ok {
+                p.open--
+                p.inUse--
+                p.grant()
+            }
+        default:
+            p.waiters.Remove(elem)
+        }
+        return nil, ctx.Err()
+    }
+}
+
+// put hands db to the oldest waiter, or makes it idle if nobody waits.
+// Callers hold p.mu.
+func (p *Pool) put(db *Database) {
+    if p.closed {
+        p.open--
+        db.Close()
+        return
+    }
+    if front := p.waiters.Front(); front != nil {
+        p.waiters.Remove(front)
+        front.Value.(chan *Database) <- db
+        return
+    }
+    p.idle = append(p.idle, db)
+}
+
+// grant lets the oldest waiter dial a connection of its own when the pool is
+// below max, as after a failed dial or a discarded connection. The waiter is
+// handed nil with the connection already counted. Callers hold p.mu.
+func (p *Pool) grant() {
+    if p.closed || p.open >= p.max {
+        return
+    }
+    if front := p.waiters.Front(); front != nil {
+        p.waiters.Remove(front)
+        p.open++
+        p.inUse++
+        front.Value.(chan *Database) <- nil
+    }
+}
+
+func (p *Pool) dial(ctx context.Context) (*Database, error) {
+    db, err := ConnectContext(ctx, p.uri)
+    if err != nil {
+        p.mu.Lock()
+        p.open--
+        p.inUse--
+        p.grant()
+        p.mu.Unlock()
+        return nil, err
+    }
+    return db, nil
+}
+
+func (p *Pool) discard(db *Database) {
+    p.mu.Lock()
+    p.open--
+    p.grant()
+    p.mu.Unlock()
+    db.Close()
+}
+
+func (p *Pool) checkout(db *Database) (*Database, error) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    if p.closed {
+        p.open--
+        db.Close()
+        return nil, ErrClosed
+    }
+    p.inUse++
+    return db, nil
+}
+
+// Release returns db to the pool. Connections released after Close are
+// closed instead.
+func (p *Pool) Release(db *Database) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    p.inUse--
+    p.put(db)
+}
+
+func (p *Pool) Stats() PoolStats {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    return PoolStats{InUse: p.inUse, Idle: len(p.idle), Waiting: p.waiters.Len()}
+}
+
+// Close closes every idle connection and wakes blocked callers of Acquire.
+// Connections still in use are closed as they are released.
+func (p *Pool) Close() error {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    if p.closed {
+        return nil
+    }
+    p.closed = true
+    for _, db := range p.idle {
+        p.open--
+        db.Close()
+    }
+    p.idle = nil
+    for e := p.waiters.Front(); e != nil; e = e.Next() {
+        close(e.Value.(chan *Database))
+    }
+    p.waiters.Init()
+    return nil
+}
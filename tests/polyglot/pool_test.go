@@ -0,0 +1,153 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "sync"
+    "testing"
+    "time"
+)
+
+// waitFor polls p until n callers are queued in Acquire.
+func waitFor(t *testing.T, p *Pool, n int) {
+    t.Helper()
+    deadline := time.Now().Add(5 * time.Second)
+    for p.Stats().Waiting != n {
+        if time.Now().After(deadline) {
+            t.Fatalf("Waiting = %d, want %d", p.Stats().Waiting, n)
+        }
+        time.Sleep(time.Millisecond)
+    }
+}
+
+func TestPoolServesWaitersInArrivalOrder(t *testing.T) {
+    const waiters = 20
+    p, err := NewPool("mem://", 1)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer p.Close()
+    held, err := p.Acquire(context.Background())
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    var (
+        mu    sync.Mutex
+        order []int
+        wg    sync.WaitGroup
+    )
+    for i := range waiters {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            db, err := p.Acquire(context.Background())
+            if err != nil {
+                t.Error(err)
+                return
+            }
+            mu.Lock()
+            order = append(order, i)
+            mu.Unlock()
+            p.Release(db)
+        }()
+        // Queue them one at a time so that arrival order is known.
+        waitFor(t, p, i+1)
+    }
+
+    p.Release(held)
+    wg.Wait()
+    for i, got := range order {
+        if got != i {
+            t.Fatalf("waiters served in order %v, want arrival order", order)
+        }
+    }
+    if s := p.Stats(); s != (PoolStats{Idle: 1}) {
+        t.Errorf("Stats() = %+v after every waiter released", s)
+    }
+}
+
+func TestPoolCancelledWaiterLeavesQueue(t *testing.T) {
+    p, err := NewPool("mem://", 1)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer p.Close()
+    held, err := p.Acquire(context.Background())
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    ctx, cancel := context.WithCancel(context.Background())
+    errc := make(chan error)
+    go func() {
+        _, err := p.Acquire(ctx)
+        errc <- err
+    }()
+    waitFor(t, p, 1)
+    got := make(chan *Database)
+    go func() {
+        db, err := p.Acquire(context.Background())
+        if err != nil {
+            t.Error(err)
+        }
+        got <- db
+    }()
+    waitFor(t, p, 2)
+
+    cancel()
+    if err := <-errc; !errors.Is(err, context.Canceled) {
+        t.Fatalf("cancelled Acquire returned %v", err)
+    }
+    waitFor(t, p, 1)
+
+    // The connection goes to the remaining waiter, not the cancelled one.
+    p.Release(held)
+    select {
+    case db := <-got:
+        p.Release(db)
+    case <-time.After(5 * time.Second):
+        t.Fatal("remaining waiter was not served")
+    }
+    if s := p.Stats(); s != (PoolStats{Idle: 1}) {
+        t.Errorf("Stats() = %+v, want one idle connection and no waiters", s)
+    }
+}
+
+func TestPoolFailedDialServesWaiter(t *testing.T) {
+    p, err := NewPool("mem://", 1)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer p.Close()
+    // Reserve the only slot as Acquire does before it dials.
+    p.mu.Lock()
+    p.open++
+    p.inUse++
+    p.mu.Unlock()
+
+    got := make(chan *Database)
+    go func() {
+        db, err := p.Acquire(context.Background())
+        if err != nil {
+            t.Error(err)
+        }
+        got <- db
+    }()
+    waitFor(t, p, 1)
+
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+    if _, err := p.dial(ctx); err == nil {
+        t.Fatal("dial with a cancelled context succeeded")
+    }
+    select {
+    case db := <-got:
+        p.Release(db)
+    case <-time.After(5 * time.Second):
+        t.Fatal("waiter was not served after the dial failed")
+    }
+    if s := p.Stats(); s != (PoolStats{Idle: 1}) {
+        t.Errorf("Stats() = %+v, want one idle connection and no waiters", s)
+    }
+}
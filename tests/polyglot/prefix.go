@@ -0,0 +1,117 @@
+package main
+
+import (
+    "context"
+    "strings"
+)
+
+type keyLister interface {
+    Keys() []string
+}
+
+// PrefixStorage gives each namespace its own key space on a shared Storage
+// by storing every key as prefix + ":" + key.
+type PrefixStorage struct {
+    inner  Storage
+    prefix string
+}
+
+var _ Storage = (*PrefixStorage)(nil)
+
+func NewPrefixStorage(s Storage, prefix string) *PrefixStorage {
+    return &PrefixStorage{inner: s, prefix: prefix + ":"}
+}
+
+// Namespace returns a view of db whose keys are isolated under prefix.
+func (db *Database) Namespace(prefix string) Storage {
+    return NewPrefixStorage(db, prefix)
+}
+
+func (p *PrefixStorage) Save(key, data string) (uint64, error) {
+    return p.inner.Save(p.prefix+key, data)
+}
+
+func (p *PrefixStorage) SaveContext(ctx context.Context, key, data string) (uint64, error) {
+    return saveContext(ctx, p.inner, p.prefix+key, data)
+}
+
+func (p *PrefixStorage) Load(key string) (string, error) {
+    return p.inner.Load(p.prefix + key)
+}
+
+func (p *PrefixStorage) LoadContext(ctx context.Context, key string) (string, error) {
+    return loadContext(ctx, p.inner, p.prefix+key)
+}
+
+func (p *PrefixStorage) SaveBatch(ctx context.Context, items map[string]string) error {
+    prefixed := make(map[string]string, len(items))
+    for k, v := range items {
+        prefixed[p.prefix+k] = v
+    }
+    return p.unprefix(p.inner.SaveBatch(ctx, prefixed))
+}
+
+func (p *PrefixStorage) Delete(key string) error {
+    return p.inner.Delete(p.prefix + key)
+}
+
+func (p *PrefixStorage) LoadMany(ctx context.Context, keys []string) (map[string]string, error) {
+    prefixed := make([]string, len(keys))
+    for i, k := range keys {
+        prefixed[i] = p.prefix + k
+    }
+    found, err := p.inner.LoadMany(ctx, prefixed)
+    if err != nil {
+        return nil, p.unprefix(err)
+    }
+    unprefixed := make(map[string]string, len(found))
+    for k, v := range found {
+        unprefixed[strings.TrimPrefix(k, p.prefix)] = v
+    }
+    return unprefixed, nil
+}
+
+func (p *PrefixStorage) DeleteMany(ctx context.Context, keys []string) error {
+    prefixed := make([]string, len(keys))
+    for i, k := range keys {
+        prefixed[i] = p.prefix + k
+    }
+    return p.unprefix(p.inner.DeleteMany(ctx, prefixed))
+}
+
+// unprefix strips the prefix from the KeyErrors in err, so FailedKeys
+// returns keys that can be passed back to p.
+func (p *PrefixStorage) unprefix(err error) error {
+    for _, ke := range keyErrors(err) {
+        ke.Key = strings.TrimPrefix(ke.Key, p.prefix)
+    }
+    return err
+}
+
+func (p *PrefixStorage) Has(ctx context.Context, key string) (bool, error) {
+    return p.inner.Has(ctx, p.prefix+key)
+}
+
+func (p *PrefixStorage) Sync(ctx context.Context) error {
+    return p.inner.Sync(ctx)
+}
+
+func (p *PrefixStorage) Ping(ctx context.Context) error {
+    return p.inner.Ping(ctx)
+}
+
+// Keys returns the keys in this namespace with the prefix removed, or nil if
+// the underlying Storage cannot list its keys.
+func (p *PrefixStorage) Keys() []string {
+    lister, ok := p.inner.(keyLister)
+    if !ok {
+        return nil
+    }
+    var keys []string
+    for _, k := range lister.Keys() {
+        if rest, ok := strings.CutPrefix(k, p.prefix); ok {
+            keys = append(keys, rest)
+        }
+    }
+    return keys
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+    "context"
+    "strings"
+)
+
+// deletePrefixBatch is how many keys DeletePrefix removes per write lock.
+const deletePrefixBatch = 1000
+
+// DeletePrefix removes every key that starts with prefix and returns how many
+// live keys it removed. Matching keys are collected under the read lock and
+// then deleted in batches, each under its own write lock, so readers and
+// Iterate make progress in between. If ctx is done between batches it
+// returns the count so far with ctx.Err(). Keys saved after the scan are
+// not removed. The KeyNormalizer, if any, applies to prefix as well.
+func (db *Database) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+    if db.opts.KeyNormalizer != nil {
+        prefix = db.opts.KeyNormalizer(prefix)
+    }
+    var n int
+    err := db.run(ctx, "delete_prefix", prefix, func(ctx context.Context) error {
+        db.mu.RLock()
+        if db.closed {
+            db.mu.RUnlock()
+            return ErrClosed
+        }
+        var keys []string
+        for k := range db.data {
+            if strings.HasPrefix(k, prefix) {
+                keys = append(keys, k)
+            }
+        }
+        db.mu.RUnlock()
+
+        for len(keys) > 0 {
+            if err := ctx.Err(); err != nil {
+                return err
+            }
+            batch := keys[:min(deletePrefixBatch, len(keys))]
+            keys = keys[len(batch):]
+
+            db.mu.Lock()
+            if db.closed {
+                db.mu.Unlock()
+                return ErrClosed
+            }
+            for _, k := range batch {
+                if db.del(k) {
+                    n++
+                }
+            }
+            db.mu.Unlock()
+        }
+        return nil
+    })
+    return n, err
+}
@@ -0,0 +1,48 @@
+// Package promobserver exports storage operation metrics to Prometheus.
+package promobserver
+
+import (
+    "time"
+
+    "github.com/prometheus/client_golang/prometheus"
+)
+
+// PrometheusObserver counts operations and records their latency. Keys are
+// not used as labels to keep cardinality bounded.
+type PrometheusObserver struct {
+    ops     *prometheus.CounterVec
+    latency *prometheus.HistogramVec
+}
+
+// New creates a PrometheusObserver and registers its collectors with reg.
+func New(reg prometheus.Registerer) (*PrometheusObserver, error) {
+    o := &PrometheusObserver{
+        ops: prometheus.NewCounterVec(prometheus.CounterOpts{
+            Namespace: "storage",
+            Name:      "operations_total",
+            Help:      "Storage operations by op and result.",
+        }, []string{"op", "result"}),
+        latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+            Namespace: "storage",
+            Name:      "operation_duration_seconds",
+            Help:      "Storage operation latency by op.",
+            Buckets:   prometheus.DefBuckets,
+        }, []string{"op"}),
+    }
+    if err := reg.Register(o.ops); err != nil {
+        return nil, err
+    }
+    if err := reg.Register(o.latency); err != nil {
+        return nil, err
+    }
+    return o, nil
+}
+
+func (o *PrometheusObserver) ObserveOp(op string, key string, dur time.Duration, err error) {
+    result := "ok"
+    if err != nil {
+        result = "error"
+    }
+    o.ops.WithLabelValues(op, result).Inc()
+    o.latency.WithLabelValues(op).Observe(dur.Seconds())
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "strconv"
+    "sync"
+    "testing"
+    "time"
+)
+
+// TestDatabaseConcurrentUse hammers one *Database from many goroutines. Run
+// it with -race.
+func TestDatabaseConcurrentUse(t *testing.T) {
+    const (
+        workers = 16
+        rounds  = 200
+    )
+    ctx := context.Background()
+    db, err := ConnectWithOptions(ctx, "mem://", ConnectOptions{
+        SweepInterval: time.Millisecond,
+        LRUEntries:    64,
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer db.Close()
+    // Counted apart from db, whose LRU could evict the counter.
+    counters, err := ConnectContext(ctx, "mem://")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer counters.Close()
+
+    var wg sync.WaitGroup
+    for w := range workers {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for i := range rounds {
+                k := "k" + strconv.Itoa(i%10)
+                db.Save(k, fmt.Sprint(w, i))
+                db.Save(fmt.Sprint("u", w, "/", i), "evicted soon")
+                db.Load(k)
+                db.Has(ctx, k)
+                db.SaveWithTTL(ctx, "ttl"+k, "v", time.Microsecond)
+                db.SaveBatch(ctx, map[string]string{k: "b", "p/" + k: "b"})
+                db.CompareAndSwap(ctx, k, "b", "c")
+                db.Delete(k)
+                db.Keys()
+                db.Len()
+                db.Iterate(ctx, func(key, _ string) error {
+                    _, err := db.Load(key)
+                    _ = err
+                    return nil
+                })
+                if tx, err := db.Begin(ctx); err == nil {
+                    tx.Save(k, "tx")
+                    tx.Commit()
+                }
+                db.Namespace("n").Save(k, "ns")
+                db.Increment(ctx, k, 1)
+                if _, err := counters.Increment(ctx, "counter", 1); err != nil {
+                    t.Error(err)
+                    return
+                }
+                if i%50 == 0 {
+                    db.Resize(32 + w)
+                    db.DeletePrefix(ctx, "p/")
+                    if clone, err := db.Clone(); err == nil {
+                        clone.Close()
+                    }
+                }
+            }
+        }()
+    }
+    wg.Wait()
+
+    got, err := counters.Load("counter")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if want := strconv.Itoa(workers * rounds); got != want {
+        t.Errorf("counter = %s, want %s", got, want)
+    }
+    if n := db.Len(); n > 32+workers-1 {
+        t.Errorf("Len() = %d, over the LRU bound", n)
+    }
+}
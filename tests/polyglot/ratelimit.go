@@ -0,0 +1,125 @@
+package main
+
+import (
+    "context"
+    "fmt"
+
+    "golang.org/x/time/rate"
+)
+
+type RateLimitOption func(*RateLimitedStorage)
+
+// LimitReads makes Load and Has wait for the limiter as well.
+func LimitReads() RateLimitOption {
+    return func(r *RateLimitedStorage) { r.limitReads = true }
+}
+
+// RateLimitedStorage caps the rate of writes reaching the wrapped Storage.
+// Each saved or deleted key costs one token; reads are free unless
+// LimitReads is set. Callers wait for tokens until their context is done.
+type RateLimitedStorage struct {
+    inner      Storage
+    limiter    *rate.Limiter
+    limitReads bool
+}
+
+var _ Storage = (*RateLimitedStorage)(nil)
+
+func NewRateLimited(s Storage, r rate.Limit, burst int, opts ...RateLimitOption) *RateLimitedStorage {
+    rl := &RateLimitedStorage{inner: s, limiter: rate.NewLimiter(r, burst)}
+    for _, opt := range opts {
+        opt(rl)
+    }
+    return rl
+}
+
+// wait takes n tokens, at most a burst at a time. When the wait cannot finish
+// before ctx's deadline it fails with context.DeadlineExceeded right away
+// rather than sleeping until the deadline.
+func (r *RateLimitedStorage) wait(ctx context.Context, n int) error {
+    for n > 0 {
+        step := min(n, max(r.limiter.Burst(), 1))
+        if err := r.limiter.WaitN(ctx, step); err != nil {
+            if ctxErr := ctx.Err(); ctxErr != nil {
+                return ctxErr
+            }
+            if _, ok := ctx.Deadline(); ok {
+                return fmt.Errorf("%w: %v", context.DeadlineExceeded, err)
+            }
+            return err
+        }
+        n -= step
+    }
+    return nil
+}
+
+func (r *RateLimitedStorage) Save(key, data string) (uint64, error) {
+    return r.SaveContext(context.Background(), key, data)
+}
+
+func (r *RateLimitedStorage) SaveContext(ctx context.Context, key, data string) (uint64, error) {
+    if err := r.wait(ctx, 1); err != nil {
+        return 0, err
+    }
+    return saveContext(ctx, r.inner, key, data)
+}
+
+func (r *RateLimitedStorage) Load(key string) (string, error) {
+    return r.LoadContext(context.Background(), key)
+}
+
+func (r *RateLimitedStorage) LoadContext(ctx context.Context, key string) (string, error) {
+    if r.limitReads {
+        if err := r.wait(ctx, 1); err != nil {
+            return "", err
+        }
+    }
+    return loadContext(ctx, r.inner, key)
+}
+
+func (r *RateLimitedStorage) SaveBatch(ctx context.Context, items map[string]string) error {
+    if err := r.wait(ctx, len(items)); err != nil {
+        return err
+    }
+    return r.inner.SaveBatch(ctx, items)
+}
+
+func (r *RateLimitedStorage) Delete(key string) error {
+    if err := r.wait(context.Background(), 1); err != nil {
+        return err
+    }
+    return r.inner.Delete(key)
+}
+
+func (r *RateLimitedStorage) LoadMany(ctx context.Context, keys []string) (map[string]string, error) {
+    if r.limitReads {
+        if err := r.wait(ctx, len(keys)); err != nil {
+            return nil, err
+        }
+    }
+    return r.inner.LoadMany(ctx, keys)
+}
+
+func (r *RateLimitedStorage) DeleteMany(ctx context.Context, keys []string) error {
+    if err := r.wait(ctx, len(keys)); err != nil {
+        return err
+    }
+    return r.inner.DeleteMany(ctx, keys)
+}
+
+func (r *RateLimitedStorage) Has(ctx context.Context, key string) (bool, error) {
+    if r.limitReads {
+        if err := r.wait(ctx, 1); err != nil {
+            return false, err
+        }
+    }
+    return r.inner.Has(ctx, key)
+}
+
+func (r *RateLimitedStorage) Ping(ctx context.Context) error {
+    return r.inner.Ping(ctx)
+}
+
+func (r *RateLimitedStorage) Sync(ctx context.Context) error {
+    return r.inner.Sync(ctx)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "sort"
+    "strings"
+    "sync"
+)
+
+// openFunc opens a Storage for Connect. Built-in backends receive the
+// options and a context bounded by DialTimeout; factories added with Register
+// are adapted to ignore both.
+type openFunc func(ctx context.Context, uri string, opts ConnectOptions) (Storage, error)
+
+var (
+    factoriesMu sync.RWMutex
+    factories   = make(map[string]openFunc)
+)
+
+func init() {
+    for _, scheme := range memSchemes {
+        register(scheme, func(ctx context.Context, uri string, opts ConnectOptions) (Storage, error) {
+            if opts.Shards > 1 {
+                return NewShardedStorage(ctx, uri, opts)
+            }
+            db, err := ConnectWithOptions(ctx, uri, opts)
+            if err != nil {
+                return nil, err
+            }
+            return db, nil
+        })
+    }
+}
+
+// Register makes a Storage factory available to Connect for URIs with the
+// given scheme. It panics if factory is nil or the scheme is already taken.
+func Register(scheme string, factory func(uri string) (Storage, error)) {
+    if factory == nil {
+        panic("storage: Register factory is nil")
+    }
+    register(scheme, func(_ context.Context, uri string, _ ConnectOptions) (Storage, error) {
+        return factory(uri)
+    })
+}
+
+func register(scheme string, open openFunc) {
+    factoriesMu.Lock()
+    defer factoriesMu.Unlock()
+    if _, dup := factories[scheme]; dup {
+        panic("storage: Register called twice for scheme " + scheme)
+    }
+    factories[scheme] = open
+}
+
+// Connect opens the Storage registered for the scheme of uri. URIs without a
+// scheme, and mem:// URIs, open an in-memory Database. Options start from the
+// zero ConnectOptions, are then set from the query parameters of uri (see
+// queryOptions), and finally by opts, so an explicit option always wins over
+// the URI.
+func Connect(uri string, opts ...Option) (Storage, error) {
+    u, err := parseURI(uri)
+    if err != nil {
+        return nil, fmt.Errorf("connect %q: %w", uri, err)
+    }
+    var o ConnectOptions
+    if err := queryOptions(u, &o); err != nil {
+        return nil, fmt.Errorf("connect %q: %w", uri, err)
+    }
+    for _, opt := range opts {
+        opt(&o)
+    }
+    factoriesMu.RLock()
+    open, ok := factories[u.Scheme]
+    factoriesMu.RUnlock()
+    if !ok {
+        return nil, fmt.Errorf("connect %q: %w %q (registered: %s)",
+            uri, ErrUnknownScheme, u.Scheme, strings.Join(registeredSchemes(), ", "))
+    }
+
+    ctx := context.Background()
+    if o.DialTimeout > 0 {
+        var cancel context.CancelFunc
+        ctx, cancel = context.WithTimeout(ctx, o.DialTimeout)
+        defer cancel()
+    }
+    s, err := open(ctx, uri, o)
+    if err != nil {
+        return nil, fmt.Errorf("connect %q: %w", uri, err)
+    }
+    if o.Namespace != "" {
+        s = NewPrefixStorage(s, o.Namespace)
+    }
+    return s, nil
+}
+
+func registeredSchemes() []string {
+    factoriesMu.RLock()
+    defer factoriesMu.RUnlock()
+    schemes := make([]string, 0, len(factories))
+    for scheme := range factories {
+        if scheme == "" {
+            scheme = `""`
+        }
+        schemes = append(schemes, scheme)
+    }
+    sort.Strings(schemes)
+    return schemes
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "math/rand/v2"
+    "time"
+)
+
+type RetryOptions struct {
+    MaxAttempts int
+    BaseDelay   time.Duration
+    MaxDelay    time.Duration
+}
+
+type permanentError struct {
+    err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying.
+func Permanent(err error) error {
+    if err == nil {
+        return nil
+    }
+    return &permanentError{err: err}
+}
+
+// RetryStorage retries failed saves on the wrapped Storage with exponential
+// backoff and jitter. Loads are passed through unchanged.
+type RetryStorage struct {
+    inner Storage
+    opts  RetryOptions
+}
+
+func NewRetryStorage(s Storage, opts RetryOptions) *RetryStorage {
+    if opts.MaxAttempts <= 0 {
+        opts.MaxAttempts = 3
+    }
+    if opts.BaseDelay <= 0 {
+        opts.BaseDelay = 10 * time.Millisecond
+    }
+    if opts.MaxDelay <= 0 {
+        opts.MaxDelay = time.Second
+    }
+    return &RetryStorage{inner: s, opts: opts}
+}
+
+func (r *RetryStorage) Save(key, data string) (uint64, error) {
+    return r.SaveContext(context.Background(), key, data)
+}
+
+func (r *RetryStorage) SaveContext(ctx context.Context, key, data string) (uint64, error) {
+    for attempt := 1; ; attempt++ {
+        rev, err := saveContext(ctx, r.inner, key, data)
+        if err == nil {
+            return rev, nil
+        }
+        var perm *permanentError
+        if errors.As(err, &perm) {
+            return 0, perm.err
+        }
+        if ctx.Err() != nil {
+            return 0, err
+        }
+        if attempt == r.opts.MaxAttempts {
+            return 0, fmt.Errorf("save %q: giving up after %d attempts: %w", key, attempt, err)
+        }
+        t := time.NewTimer(r.backoff(attempt))
+        select {
+        case <-t.C:
+        case <-ctx.Done():
+            t.Stop()
+            return 0, fmt.Errorf("save %q: %w after %d attempts (last error: %v)", key, ctx.Err(), attempt, err)
+        }
+    }
+}
+
+// SaveBatch is passed through without retries; use FailedKeys on its error to
+// resubmit only the keys that failed.
+func (r *RetryStorage) SaveBatch(ctx context.Context, items map[string]string) error {
+    return r.inner.SaveBatch(ctx, items)
+}
+
+func (r *RetryStorage) Load(key string) (string, error) {
+    return r.inner.Load(key)
+}
+
+func (r *RetryStorage) LoadContext(ctx context.Context, key string) (string, error) {
+    return loadContext(ctx, r.inner, key)
+}
+
+func (r *RetryStorage) LoadMany(ctx context.Context, keys []string) (map[string]string, error) {
+    return r.inner.LoadMany(ctx, keys)
+}
+
+func (r *RetryStorage) DeleteMany(ctx context.Context, keys []string) error {
+    return r.inner.DeleteMany(ctx, keys)
+}
+
+func (r *RetryStorage) Delete(key string) error {
+    return r.inner.Delete(key)
+}
+
+func (r *RetryStorage) Has(ctx context.Context, key string) (bool, error) {
+    return r.inner.Has(ctx, key)
+}
+
+func (r *RetryStorage) Sync(ctx context.Context) error {
+    return r.inner.Sync(ctx)
+}
+
+func (r *RetryStorage) Ping(ctx context.Context) error {
+    return r.inner.Ping(ctx)
+}
+
+// backoff returns the delay before the attempt following attempt n: the base
+// delay doubled per attempt, capped at MaxDelay, with the upper half jittered.
+func (r *RetryStorage) backoff(n int) time.Duration {
+    d := r.opts.BaseDelay << (n - 1)
+    if d <= 0 || d > r.opts.MaxDelay {
+        d = r.opts.MaxDelay
+    }
+    return d/2 + rand.N(d/2+1)
+}
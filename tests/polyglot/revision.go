@@ -0,0 +1,96 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "time"
+)
+
+// LoadWithRevision returns the value stored under key together with its
+// revision, for a later SaveWithExpectedRevision.
+func (db *Database) LoadWithRevision(ctx context.Context, key string) (string, uint64, error) {
+    e, err := db.loadEntry(ctx, key)
+    if err != nil {
+        return "", 0, err
+    }
+    return string(e.value), e.rev, nil
+}
+
+// LoadIfChanged is LoadWithRevision for callers that already hold the value
+// at revision knownRev: if that is still the stored revision it reports
+// changed as false and returns no value. A missing key is ErrNotFound
+// whatever knownRev is.
+func (db *Database) LoadIfChanged(ctx context.Context, key string, knownRev uint64) (value string, rev uint64, changed bool, err error) {
+    e, err := db.loadEntry(ctx, key)
+    if err != nil {
+        return "", 0, false, err
+    }
+    if e.rev == knownRev {
+        return "", e.rev, false, nil
+    }
+    return string(e.value), e.rev, true, nil
+}
+
+// loadEntry returns the live entry under key. Its value must not be
+// modified.
+func (db *Database) loadEntry(ctx context.Context, key string) (entry, error) {
+    var e entry
+    err := db.run(ctx, "load", key, func(ctx context.Context) error {
+        key, err := db.key(key)
+        if err != nil {
+            return err
+        }
+        db.mu.RLock()
+        defer db.mu.RUnlock()
+        if db.closed {
+            return ErrClosed
+        }
+        if err := ctx.Err(); err != nil {
+            return err
+        }
+        var ok bool
+        e, ok = db.data[key]
+        found := ok && !e.expired(time.Now())
+        db.countLoad(found)
+        if !found {
+            return ErrNotFound
+        }
+        db.touch(e)
+        return nil
+    })
+    return e, err
+}
+
+// SaveWithExpectedRevision saves data under key only if the stored revision
+// is still expected, and returns the new revision. An expected revision of 0
+// means key must not exist. Otherwise it fails with ErrRevisionMismatch.
+func (db *Database) SaveWithExpectedRevision(ctx context.Context, key, data string, expected uint64) (uint64, error) {
+    var rev uint64
+    err := db.run(ctx, "save", key, func(ctx context.Context) error {
+        key, err := db.key(key)
+        if err != nil {
+            return err
+        }
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        if db.closed {
+            return ErrClosed
+        }
+        if err := ctx.Err(); err != nil {
+            return err
+        }
+        var cur uint64
+        if e, ok := db.data[key]; ok && !e.expired(time.Now()) {
+            cur = e.rev
+        }
+        if cur != expected {
+            return fmt.Errorf("%w: expected %d, have %d", ErrRevisionMismatch, expected, cur)
+        }
+        if err := db.checkPut(key, len(data)); err != nil {
+            return err
+        }
+        rev = db.put(key, []byte(data), 0)
+        return nil
+    })
+    return rev, err
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "hash/maphash"
+    "maps"
+    "slices"
+)
+
+// ShardedStorage spreads keys over several Databases by hash, each with its
+// own lock, so operations on keys in different shards do not contend.
+// Operations that span keys, like SaveBatch and Iterate, visit the shards
+// one at a time and are not atomic across them.
+type ShardedStorage struct {
+    seed      maphash.Seed
+    shards    []*Database
+    normalize func(string) string // KeyNormalizer, applied before hashing
+}
+
+var _ Storage = (*ShardedStorage)(nil)
+
+// NewShardedStorage opens opts.Shards in-memory Databases for uri, or one if
+// Shards is not positive. MaxKeys and LRUEntries are bounds on the whole
+// storage: each shard gets an even share, which must be at least one.
+func NewShardedStorage(ctx context.Context, uri string, opts ConnectOptions) (*ShardedStorage, error) {
+    n := max(opts.Shards, 1)
+    if opts.MaxKeys > 0 && opts.MaxKeys < n {
+        return nil, fmt.Errorf("MaxKeys %d is less than one key per shard of %d", opts.MaxKeys, n)
+    }
+    if opts.LRUEntries > 0 && opts.LRUEntries < n {
+        return nil, fmt.Errorf("LRUEntries %d is less than one entry per shard of %d", opts.LRUEntries, n)
+    }
+    s := &ShardedStorage{
+        seed:      maphash.MakeSeed(),
+        shards:    make([]*Database, n),
+        normalize: opts.KeyNormalizer,
+    }
+    for i := range s.shards {
+        shardOpts := opts
+        shardOpts.MaxKeys = share(opts.MaxKeys, n, i)
+        shardOpts.LRUEntries = share(opts.LRUEntries, n, i)
+        db, err := ConnectWithOptions(ctx, uri, shardOpts)
+        if err != nil {
+            s.Close()
+            return nil, err
+        }
+        s.shards[i] = db
+    }
+    return s, nil
+}
+
+// share is shard i's part of total split between n shards; the first
+// total%n shards take one more.
+func share(total, n, i int) int {
+    if total <= 0 {
+        return total
+    }
+    part := total / n
+    if i < total%n {
+        part++
+    }
+    return part
+}
+
+// index returns the shard of key. Keys are hashed in their normalized form,
+// so that keys the KeyNormalizer maps together share a shard; the shard then
+// normalizes and validates the key itself.
+func (s *ShardedStorage) index(key string) int {
+    if s.normalize != nil {
+        key = s.normalize(key)
+    }
+    return int(maphash.String(s.seed, key) % uint64(len(s.shards)))
+}
+
+func (s *ShardedStorage) shard(key string) *Database {
+    return s.shards[s.index(key)]
+}
+
+func (s *ShardedStorage) Save(key, data string) (uint64, error) {
+    return s.shard(key).Save(key, data)
+}
+
+func (s *ShardedStorage) SaveContext(ctx context.Context, key, data string) (uint64, error) {
+    return s.shard(key).SaveContext(ctx, key, data)
+}
+
+func (s *ShardedStorage) Load(key string) (string, error) {
+    return s.shard(key).Load(key)
+}
+
+func (s *ShardedStorage) LoadContext(ctx context.Context, key string) (string, error) {
+    return s.shard(key).LoadContext(ctx, key)
+}
+
+// SaveBatch saves each shard's part of items in turn. Like Database.SaveBatch
+// it is not atomic; FailedKeys reports the keys that were not saved.
+func (s *ShardedStorage) SaveBatch(ctx context.Context, items map[string]string) error {
+    parts := make([]map[string]string, len(s.shards))
+    for k, v := range items {
+        i := s.index(k)
+        if parts[i] == nil {
+            parts[i] = make(map[string]string)
+        }
+        parts[i][k] = v
+    }
+    var errs []error
+    for i, part := range parts {
+        if part == nil {
+            continue
+        }
+        if err := s.shards[i].SaveBatch(ctx, part); err != nil {
+            errs = append(errs, err)
+        }
+    }
+    return errors.Join(errs...)
+}
+
+func (s *ShardedStorage) Delete(key string) error {
+    return s.shard(key).Delete(key)
+}
+
+func (s *ShardedStorage) DeleteExisting(key string) error {
+    return s.shard(key).DeleteExisting(key)
+}
+
+func (s *ShardedStorage) LoadMany(ctx context.Context, keys []string) (map[string]string, error) {
+    found := make(map[string]string, len(keys))
+    for i, part := range s.split(keys) {
+        if len(part) == 0 {
+            continue
+        }
+        got, err := s.shards[i].LoadMany(ctx, part)
+        if err != nil {
+            return nil, err
+        }
+        maps.Copy(found, got)
+    }
+    return found, nil
+}
+
+func (s *ShardedStorage) DeleteMany(ctx context.Context, keys []string) error {
+    var errs []error
+    for i, part := range s.split(keys) {
+        if len(part) == 0 {
+            continue
+        }
+        if err := s.shards[i].DeleteMany(ctx, part); err != nil {
+            errs = append(errs, err)
+        }
+    }
+    return errors.Join(errs...)
+}
+
+// split groups keys by the index of their shard.
+func (s *ShardedStorage) split(keys []string) [][]string {
+    parts := make([][]string, len(s.shards))
+    for _, k := range keys {
+        i := s.index(k)
+        parts[i] = append(parts[i], k)
+    }
+    return parts
+}
+
+func (s *ShardedStorage) Has(ctx context.Context, key string) (bool, error) {
+    return s.shard(key).Has(ctx, key)
+}
+
+func (s *ShardedStorage) Ping(ctx context.Context) error {
+    return s.shards[0].Ping(ctx)
+}
+
+func (s *ShardedStorage) Sync(ctx context.Context) error {
+    return s.shards[0].Sync(ctx)
+}
+
+// Len returns the number of live keys across all shards.
+func (s *ShardedStorage) Len() int {
+    n := 0
+    for _, db := range s.shards {
+        n += db.Len()
+    }
+    return n
+}
+
+// Stats sums the Stats of all shards.
+func (s *ShardedStorage) Stats() DatabaseStats {
+    var total DatabaseStats
+    for _, db := range s.shards {
+        st := db.Stats()
+        total.Hits += st.Hits
+        total.Misses += st.Misses
+        total.Evictions += st.Evictions
+    }
+    return total
+}
+
+// Keys returns the live keys of all shards in sorted order.
+func (s *ShardedStorage) Keys() []string {
+    var keys []string
+    for _, db := range s.shards {
+        keys = append(keys, db.Keys()...)
+    }
+    slices.Sort(keys)
+    return keys
+}
+
+// Iterate calls fn for every live entry of every shard, with the same
+// snapshot semantics as Database.Iterate within each shard.
+func (s *ShardedStorage) Iterate(ctx context.Context, fn func(key, value string) error) error {
+    for _, db := range s.shards {
+        if err := db.Iterate(ctx, fn); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// Close closes every shard.
+func (s *ShardedStorage) Close() error {
+    for _, db := range s.shards {
+        if db != nil {
+            db.Close()
+        }
+    }
+    return nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "slices"
+    "strconv"
+    "strings"
+    "sync"
+    "testing"
+)
+
+// BenchmarkSharding compares one Database with a ShardedStorage under the
+// same mix of loads and saves, nine loads to one save.
+func BenchmarkSharding(b *testing.B) {
+    for _, shards := range []int{1, 16} {
+        for _, goroutines := range []int{8, 64} {
+            b.Run(fmt.Sprintf("shards=%d/goroutines=%d", shards, goroutines), func(b *testing.B) {
+                s, err := Connect("mem://", WithShards(shards))
+                if err != nil {
+                    b.Fatal(err)
+                }
+                benchmarkMixed(b, s, goroutines)
+            })
+        }
+    }
+}
+
+func benchmarkMixed(b *testing.B, s Storage, goroutines int) {
+    const nkeys = 1024
+    keys := make([]string, nkeys)
+    for i := range keys {
+        keys[i] = "key" + strconv.Itoa(i)
+        if _, err := s.Save(keys[i], "value"); err != nil {
+            b.Fatal(err)
+        }
+    }
+    b.ResetTimer()
+    var wg sync.WaitGroup
+    for g := range goroutines {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for i := g; i < b.N; i += goroutines {
+                k := keys[i%nkeys]
+                if i%10 == 0 {
+                    s.Save(k, "value")
+                } else {
+                    s.Load(k)
+                }
+            }
+        }()
+    }
+    wg.Wait()
+}
+
+func TestShardedLimitsAreTotals(t *testing.T) {
+    s, err := Connect("mem://", WithShards(4), WithMaxKeys(10))
+    if err != nil {
+        t.Fatal(err)
+    }
+    saved := 0
+    for i := range 100 {
+        if _, err := s.Save("key"+strconv.Itoa(i), "v"); err == nil {
+            saved++
+        }
+    }
+    if saved != 10 {
+        t.Errorf("saved %d keys, want MaxKeys = 10", saved)
+    }
+
+    s, err = Connect("mem://", WithShards(4), WithLRU(10))
+    if err != nil {
+        t.Fatal(err)
+    }
+    for i := range 100 {
+        if _, err := s.Save("key"+strconv.Itoa(i), "v"); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if n := s.(*ShardedStorage).Len(); n > 10 {
+        t.Errorf("Len() = %d, over LRUEntries = 10", n)
+    }
+
+    if _, err := Connect("mem://", WithShards(4), WithMaxKeys(3)); err == nil {
+        t.Error("Connect with MaxKeys below the shard count succeeded")
+    }
+}
+
+func TestShardedRoutesNormalizedKeys(t *testing.T) {
+    s, err := Connect("mem://", WithShards(8), WithKeyNormalizer(strings.ToLower))
+    if err != nil {
+        t.Fatal(err)
+    }
+    db, err := Connect("mem://", WithKeyNormalizer(strings.ToLower))
+    if err != nil {
+        t.Fatal(err)
+    }
+    for i := range 100 {
+        key := "Key" + strconv.Itoa(i)
+        for _, st := range []Storage{s, db} {
+            if _, err := st.Save(key, "v"); err != nil {
+                t.Fatal(err)
+            }
+        }
+        got, err := s.Load(strings.ToUpper(key))
+        if err != nil || got != "v" {
+            t.Fatalf("Load(%q) = %q, %v after Save(%q)", strings.ToUpper(key), got, err, key)
+        }
+        stored, err := db.(*Database).key(key)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if shard := s.(*ShardedStorage).shard(strings.ToUpper(key)); !slices.Contains(shard.Keys(), stored) {
+            t.Fatalf("shard of %q does not hold %q", strings.ToUpper(key), stored)
+        }
+    }
+    if got, want := s.(*ShardedStorage).Keys(), db.(*Database).Keys(); !slices.Equal(got, want) {
+        t.Errorf("sharded Keys() = %v, want %v as from Database", got, want)
+    }
+    if _, err := s.Save("", "v"); !errors.Is(err, ErrInvalidKey) {
+        t.Errorf("Save of the empty key: %v, want ErrInvalidKey", err)
+    }
+}
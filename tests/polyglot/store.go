@@ -0,0 +1,90 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+)
+
+// ValueCodec converts typed values to and from stored strings.
+type ValueCodec[V any] interface {
+    Encode(v V) (string, error)
+    Decode(data string) (V, error)
+}
+
+// JSONValueCodec stores values as JSON. It is the default for Store.
+type JSONValueCodec[V any] struct{}
+
+func (JSONValueCodec[V]) Encode(v V) (string, error) {
+    b, err := json.Marshal(v)
+    return string(b), err
+}
+
+func (JSONValueCodec[V]) Decode(data string) (V, error) {
+    var v V
+    err := json.Unmarshal([]byte(data), &v)
+    return v, err
+}
+
+type StoreOption[K comparable, V any] func(*Store[K, V])
+
+// WithKeyEncoder sets how keys become storage keys. The default is
+// fmt.Sprint, which only suits key types with a stable string form.
+func WithKeyEncoder[K comparable, V any](encode func(K) string) StoreOption[K, V] {
+    return func(s *Store[K, V]) { s.encodeKey = encode }
+}
+
+func WithValueCodec[K comparable, V any](c ValueCodec[V]) StoreOption[K, V] {
+    return func(s *Store[K, V]) { s.codec = c }
+}
+
+// Store is a typed view of a Storage.
+type Store[K comparable, V any] struct {
+    backing   Storage
+    encodeKey func(K) string
+    codec     ValueCodec[V]
+}
+
+func NewStore[K comparable, V any](backing Storage, opts ...StoreOption[K, V]) *Store[K, V] {
+    s := &Store[K, V]{
+        backing:   backing,
+        encodeKey: func(k K) string { return fmt.Sprint(k) },
+        codec:     JSONValueCodec[V]{},
+    }
+    for _, opt := range opts {
+        opt(s)
+    }
+    return s
+}
+
+func (s *Store[K, V]) Put(ctx context.Context, k K, v V) error {
+    key := s.encodeKey(k)
+    data, err := s.codec.Encode(v)
+    if err != nil {
+        return fmt.Errorf("put %q: %w", key, err)
+    }
+    _, err = saveContext(ctx, s.backing, key, data)
+    return err
+}
+
+// Get returns the value for k, or the zero V and ErrNotFound if it is absent.
+func (s *Store[K, V]) Get(ctx context.Context, k K) (V, error) {
+    var zero V
+    key := s.encodeKey(k)
+    data, err := loadContext(ctx, s.backing, key)
+    if err != nil {
+        return zero, err
+    }
+    v, err := s.codec.Decode(data)
+    if err != nil {
+        return zero, fmt.Errorf("get %q: %w", key, err)
+    }
+    return v, nil
+}
+
+func (s *Store[K, V]) Delete(ctx context.Context, k K) error {
+    if err := ctx.Err(); err != nil {
+        return err
+    }
+    return s.backing.Delete(s.encodeKey(k))
+}
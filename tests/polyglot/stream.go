@@ -0,0 +1,63 @@
+package main
+
+import (
+    "bytes"
+    "context"
+    "fmt"
+    "io"
+)
+
+// ctxReader fails reads once ctx is done, so a long copy stops promptly.
+type ctxReader struct {
+    ctx context.Context
+    r   io.Reader
+}
+
+func (c ctxReader) Read(p []byte) (int, error) {
+    if err := c.ctx.Err(); err != nil {
+        return 0, err
+    }
+    return c.r.Read(p)
+}
+
+// SaveStream reads r to the end and saves its content under key, returning
+// the number of bytes stored. Nothing is saved if reading fails or ctx is
+// cancelled before r is exhausted.
+func (db *Database) SaveStream(ctx context.Context, key string, r io.Reader) (int64, error) {
+    var n int64
+    err := db.run(ctx, "save", key, func(ctx context.Context) error {
+        src := io.Reader(ctxReader{ctx: ctx, r: r})
+        if db.maxValue > 0 {
+            // One byte over the limit is enough to reject it.
+            src = io.LimitReader(src, int64(db.maxValue)+1)
+        }
+        data, err := io.ReadAll(src)
+        if err != nil {
+            return err
+        }
+        if db.maxValue > 0 && len(data) > db.maxValue {
+            // src stopped one byte past the limit; the real size is unknown.
+            return fmt.Errorf("%w: over the limit of %d bytes", ErrValueTooLarge, db.maxValue)
+        }
+        if _, err := db.save(ctx, key, data, 0); err != nil {
+            return err
+        }
+        n = int64(len(data))
+        return nil
+    })
+    return n, err
+}
+
+// LoadStream returns a reader over the value stored under key. Stored values
+// are never modified in place, so the reader needs no copy.
+func (db *Database) LoadStream(ctx context.Context, key string) (io.ReadCloser, error) {
+    var data []byte
+    err := db.run(ctx, "load", key, func(ctx context.Context) (err error) {
+        data, err = db.load(ctx, key)
+        return err
+    })
+    if err != nil {
+        return nil, err
+    }
+    return io.NopCloser(bytes.NewReader(data)), nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import "context"
+
+type EventType int
+
+const (
+    EventPut EventType = iota + 1
+    EventDelete
+    EventEvict // removed to stay within LRUEntries
+)
+
+// Event describes one mutation. Value is empty for deletes and evictions.
+type Event struct {
+    Type  EventType
+    Key   string
+    Value string
+}
+
+// subscriberBuffer is how many events a subscriber may fall behind by before
+// further events are dropped for it.
+const subscriberBuffer = 64
+
+type subscriber struct {
+    ch chan Event
+}
+
+// Subscribe returns a channel that receives an Event for every save and
+// delete made after it returns, until ctx is done or the database is closed,
+// at which point the channel is closed. Writers never wait for subscribers:
+// once a subscriber's buffer is full, events for it are dropped until it
+// catches up. Entries that expire produce no events.
+func (db *Database) Subscribe(ctx context.Context) (<-chan Event, error) {
+    db.mu.Lock()
+    defer db.mu.Unlock()
+    if db.closed {
+        return nil, &OpError{Op: "subscribe", Err: ErrClosed}
+    }
+    if err := ctx.Err(); err != nil {
+        return nil, &OpError{Op: "subscribe", Err: err}
+    }
+    sub := &subscriber{ch: make(chan Event, subscriberBuffer)}
+    db.subs[sub] = struct{}{}
+    context.AfterFunc(ctx, func() {
+        db.mu.Lock()
+        defer db.mu.Unlock()
+        db.unsubscribe(sub)
+    })
+    return sub.ch, nil
+}
+
+// unsubscribe closes sub's channel unless that already happened. Callers hold
+// db.mu.
+func (db *Database) unsubscribe(sub *subscriber) {
+    if _, ok := db.subs[sub]; !ok {
+        return
+    }
+    delete(db.subs, sub)
+    close(sub.ch)
+}
+
+// publish hands ev to every subscriber without blocking. Callers hold db.mu,
+// so subscribers see events in commit order.
+func (db *Database) publish(ev Event) {
+    for sub := range db.subs {
+        select {
+        case sub.ch <- ev:
+        default:
+        }
+    }
+}
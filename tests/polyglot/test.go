@@ -1,20 +1,545 @@
 package main
 
-import "fmt"
+import (
+    "container/list"
+    "context"
+    "fmt"
+    "io"
+    "log/slog"
+    "net/url"
+    "slices"
+    "sort"
+    "sync"
+    "sync/atomic"
+    "time"
+)
 
+// memSchemes are the schemes served by the in-memory Database.
+var memSchemes = []string{"", "mem"}
+
+type ConnectOptions struct {
+    // Logger receives a debug record for every operation. Nil discards them.
+    Logger *slog.Logger
+    // Observer, if set, is told the outcome and latency of every operation.
+    Observer Observer
+    // SweepInterval, if positive, starts a background sweep that removes
+    // expired entries at that interval.
+    SweepInterval time.Duration
+    // DialTimeout bounds how long Connect may take. Factories added with
+    // Register only see the URI and are not bounded by it.
+    DialTimeout time.Duration
+    // MaxConns caps the operations in flight on one Database; callers over
+    // the cap wait for a slot or for their context. Zero means no cap.
+    MaxConns int
+    // MaxValueSize is the largest value in bytes a save accepts. Zero means
+    // unlimited.
+    MaxValueSize int
+    // MaxKeys is the most keys the database holds; saves that would add a
+    // key beyond it fail, updates of existing keys do not. Zero means
+    // unlimited.
+    MaxKeys int
+    // Shards, if above one, makes Connect return a ShardedStorage of that
+    // many Databases. MaxKeys and LRUEntries are split evenly between them,
+    // so a save can hit its shard's share before the total is reached; the
+    // other options apply to each shard on its own.
+    Shards int
+    // DefaultTTL is the expiry of values saved without a TTL of their own.
+    // Zero means they never expire.
+    DefaultTTL time.Duration
+    // Namespace, if set, makes Connect return a PrefixStorage for it.
+    Namespace string
+    // OpTimeout, if positive, bounds each operation on its own, on top of
+    // any deadline of the caller's context.
+    OpTimeout time.Duration
+    // DeadLetter, if set, receives a DeadLetter for every item SaveBatch
+    // fails to save.
+    DeadLetter Storage
+    // KeyNormalizer, if set, maps every key passed to the Database, by any
+    // operation, to the form that is stored, so that Save("Foo") and
+    // Load("foo") can refer to the same entry. Keys and Iterate return the
+    // stored form.
+    KeyNormalizer func(string) string
+    // KeyValidator checks keys after normalization; keys it rejects fail
+    // with ErrInvalidKey. Nil rejects only the empty key.
+    KeyValidator func(string) error
+    // LRUEntries, if positive, bounds the number of entries: a save that
+    // goes over it evicts the least recently saved or loaded key.
+    LRUEntries int
+    // Tracer, if set, starts a span for every operation.
+    Tracer Tracer
+}
+
+// Option configures Connect.
+type Option func(*ConnectOptions)
+
+func WithDialTimeout(d time.Duration) Option {
+    return func(o *ConnectOptions) { o.DialTimeout = d }
+}
+
+func WithLogger(l *slog.Logger) Option {
+    return func(o *ConnectOptions) { o.Logger = l }
+}
+
+func WithMaxConns(n int) Option {
+    return func(o *ConnectOptions) { o.MaxConns = n }
+}
+
+func WithMaxValueSize(n int) Option {
+    return func(o *ConnectOptions) { o.MaxValueSize = n }
+}
+
+func WithMaxKeys(n int) Option {
+    return func(o *ConnectOptions) { o.MaxKeys = n }
+}
+
+func WithShards(n int) Option {
+    return func(o *ConnectOptions) { o.Shards = n }
+}
+
+func WithDefaultTTL(d time.Duration) Option {
+    return func(o *ConnectOptions) { o.DefaultTTL = d }
+}
+
+func InNamespace(prefix string) Option {
+    return func(o *ConnectOptions) { o.Namespace = prefix }
+}
+
+func WithOpTimeout(d time.Duration) Option {
+    return func(o *ConnectOptions) { o.OpTimeout = d }
+}
+
+func WithDeadLetter(dl Storage) Option {
+    return func(o *ConnectOptions) { o.DeadLetter = dl }
+}
+
+func WithKeyNormalizer(fn func(string) string) Option {
+    return func(o *ConnectOptions) { o.KeyNormalizer = fn }
+}
+
+func WithKeyValidator(fn func(string) error) Option {
+    return func(o *ConnectOptions) { o.KeyValidator = fn }
+}
+
+func WithLRU(maxEntries int) Option {
+    return func(o *ConnectOptions) { o.LRUEntries = maxEntries }
+}
+
+// Database is an in-memory Storage. A single *Database is safe to share
+// between goroutines: writes take an exclusive lock, while Load and the other
+// read-only methods share a read lock and do not block each other. Create one
+// with Connect or ConnectWithOptions; the zero value is not usable.
 type Database struct {
-    URI string
+    URI  string
+    opts ConnectOptions // as opened, for Clone
+
+    logger    *slog.Logger
+    observer  Observer
+    conns     chan struct{} // in-flight slots; nil when MaxConns is unset
+    maxValue  int
+    maxKeys   int
+    ttl       time.Duration // DefaultTTL
+    opTimeout time.Duration
+
+    mu     sync.RWMutex
+    data   map[string]entry
+    tombs  map[string]uint64 // last revision of removed keys, see put
+    closed bool
+    stop   chan struct{}
+    subs   map[*subscriber]struct{}
+    lru    *list.List // of keys, most recent first; nil without LRUEntries
+    lruMu  sync.Mutex // serializes lru updates made under the read lock
+
+    evictMu    sync.Mutex
+    evicted    []string // evicted keys not yet reported, see reportEvictions
+    hasEvicted atomic.Bool
+    hits       atomic.Uint64 // see Stats
+    misses     atomic.Uint64
+    evictions  atomic.Uint64
 }
 
+var _ io.Closer = (*Database)(nil)
+
+// Storage is a key/value store. Only FileStorage is durable: once its Sync
+// returns, or after every write when WithFsync is set, data survives a crash.
+// Database and MemoryStorage keep everything in memory, and their Sync does
+// nothing.
+//
+// Save returns the revision of the value it stored. Database counts revisions
+// per key, starting at 1, and a key deleted and saved again continues from
+// its old revision, so a revision never names two values of one key.
+// Backends that do not track revisions return 0.
 type Storage interface {
-    Save(data string) error
+    Save(key, data string) (uint64, error)
+    Load(key string) (string, error)
+    SaveBatch(ctx context.Context, items map[string]string) error
+    Delete(key string) error
+    Ping(ctx context.Context) error
+    Has(ctx context.Context, key string) (bool, error)
+    // Sync flushes pending writes to durable storage.
+    Sync(ctx context.Context) error
+    // LoadMany returns the values of the keys that exist; missing keys are
+    // left out and are not an error. Backends without a batch read can use
+    // LoadEach.
+    LoadMany(ctx context.Context, keys []string) (map[string]string, error)
+    // DeleteMany removes keys; missing keys are not an error. Backends
+    // without a batch delete can use DeleteEach.
+    DeleteMany(ctx context.Context, keys []string) error
+}
+
+type contextSaver interface {
+    SaveContext(ctx context.Context, key, data string) (uint64, error)
+}
+
+// saveContext uses s.SaveContext when s supports it and otherwise only
+// checks ctx before the plain Save.
+func saveContext(ctx context.Context, s Storage, key, data string) (uint64, error) {
+    if cs, ok := s.(contextSaver); ok {
+        return cs.SaveContext(ctx, key, data)
+    }
+    if err := ctx.Err(); err != nil {
+        return 0, err
+    }
+    return s.Save(key, data)
 }
 
-func (db *Database) Save(data string) error {
-    fmt.Printf("Saving to %s: %s\n", db.URI, data)
+type contextLoader interface {
+    LoadContext(ctx context.Context, key string) (string, error)
+}
+
+// loadContext is the Load counterpart of saveContext.
+func loadContext(ctx context.Context, s Storage, key string) (string, error) {
+    if cl, ok := s.(contextLoader); ok {
+        return cl.LoadContext(ctx, key)
+    }
+    if err := ctx.Err(); err != nil {
+        return "", err
+    }
+    return s.Load(key)
+}
+
+func (db *Database) Save(key, data string) (uint64, error) {
+    return db.SaveContext(context.Background(), key, data)
+}
+
+func (db *Database) SaveContext(ctx context.Context, key, data string) (uint64, error) {
+    var rev uint64
+    err := db.run(ctx, "save", key, func(ctx context.Context) (err error) {
+        rev, err = db.save(ctx, key, []byte(data), 0)
+        return err
+    })
+    return rev, err
+}
+
+func (db *Database) save(ctx context.Context, key string, data []byte, ttl time.Duration) (uint64, error) {
+    key, err := db.key(key)
+    if err != nil {
+        return 0, err
+    }
+    db.mu.Lock()
+    defer db.mu.Unlock()
+    if db.closed {
+        return 0, ErrClosed
+    }
+    // Checked under the lock so a cancelled call never commits.
+    if err := ctx.Err(); err != nil {
+        return 0, err
+    }
+    if err := db.checkPut(key, len(data)); err != nil {
+        return 0, err
+    }
+    return db.put(key, data, ttl), nil
+}
+
+// put stores data under key, taking ownership of it, and returns its new
+// revision; a zero ttl falls back to DefaultTTL. Callers hold db.mu.
+func (db *Database) put(key string, data []byte, ttl time.Duration) uint64 {
+    if ttl == 0 {
+        ttl = db.ttl
+    }
+    now := time.Now()
+    e := entry{value: data, rev: 1}
+    prev, had := db.data[key]
+    if had {
+        e.rev = prev.rev + 1
+    } else if last, ok := db.tombs[key]; ok {
+        e.rev = last + 1
+        delete(db.tombs, key)
+    }
+    if ttl > 0 {
+        e.expires = now.Add(ttl)
+    }
+    if db.lru != nil {
+        if had {
+            e.elem = prev.elem
+            db.lru.MoveToFront(e.elem)
+        } else {
+            e.elem = db.lru.PushFront(key)
+        }
+    }
+    db.data[key] = e
+    if len(db.subs) > 0 {
+        db.publish(Event{Type: EventPut, Key: key, Value: string(data)})
+    }
+    db.evict()
+    return e.rev
+}
+
+// del removes key and reports whether it held a live value. Callers hold
+// db.mu.
+func (db *Database) del(key string) bool {
+    e, ok := db.data[key]
+    if !ok {
+        return false
+    }
+    db.unlink(key, e)
+    if e.expired(time.Now()) {
+        return false
+    }
+    if len(db.subs) > 0 {
+        db.publish(Event{Type: EventDelete, Key: key})
+    }
+    return true
+}
+
+func (db *Database) Load(key string) (string, error) {
+    return db.LoadContext(context.Background(), key)
+}
+
+func (db *Database) LoadContext(ctx context.Context, key string) (string, error) {
+    var data []byte
+    err := db.run(ctx, "load", key, func(ctx context.Context) (err error) {
+        data, err = db.load(ctx, key)
+        return err
+    })
+    return string(data), err
+}
+
+// load returns the stored slice itself; callers must copy it before handing
+// it out.
+func (db *Database) load(ctx context.Context, key string) ([]byte, error) {
+    key, err := db.key(key)
+    if err != nil {
+        return nil, err
+    }
+    db.mu.RLock()
+    if db.closed {
+        db.mu.RUnlock()
+        return nil, ErrClosed
+    }
+    if err := ctx.Err(); err != nil {
+        db.mu.RUnlock()
+        return nil, err
+    }
+    e, ok := db.data[key]
+    expired := ok && e.expired(time.Now())
+    if ok && !expired {
+        db.touch(e)
+    }
+    db.mu.RUnlock()
+    db.countLoad(ok && !expired)
+    if !ok {
+        return nil, ErrNotFound
+    }
+    if expired {
+        db.purge(key)
+        return nil, ErrNotFound
+    }
+    return e.value, nil
+}
+
+// Has reports whether key has a live value without copying it.
+func (db *Database) Has(ctx context.Context, key string) (bool, error) {
+    var found bool
+    err := db.run(ctx, "has", key, func(ctx context.Context) error {
+        key, err := db.key(key)
+        if err != nil {
+            return err
+        }
+        db.mu.RLock()
+        defer db.mu.RUnlock()
+        if db.closed {
+            return ErrClosed
+        }
+        if err := ctx.Err(); err != nil {
+            return err
+        }
+        e, ok := db.data[key]
+        found = ok && !e.expired(time.Now())
+        if found {
+            db.touch(e)
+        }
+        db.countLoad(found)
+        return nil
+    })
+    return found, err
+}
+
+// Keys returns the live keys in sorted order.
+func (db *Database) Keys() []string {
+    db.mu.RLock()
+    defer db.mu.RUnlock()
+    now := time.Now()
+    keys := make([]string, 0, len(db.data))
+    for k, e := range db.data {
+        if !e.expired(now) {
+            keys = append(keys, k)
+        }
+    }
+    sort.Strings(keys)
+    return keys
+}
+
+// Len returns the number of live keys.
+func (db *Database) Len() int {
+    db.mu.RLock()
+    defer db.mu.RUnlock()
+    now := time.Now()
+    n := 0
+    for _, e := range db.data {
+        if !e.expired(now) {
+            n++
+        }
+    }
+    return n
+}
+
+// Delete removes key. Deleting a missing key is not an error; use
+// DeleteExisting to tell the two apart.
+func (db *Database) Delete(key string) error {
+    return db.run(context.Background(), "delete", key, func(context.Context) error {
+        _, err := db.remove(key)
+        return err
+    })
+}
+
+// DeleteExisting removes key, returning ErrNotFound if it was not present.
+func (db *Database) DeleteExisting(key string) error {
+    return db.run(context.Background(), "delete", key, func(context.Context) error {
+        found, err := db.remove(key)
+        if err == nil && !found {
+            return ErrNotFound
+        }
+        return err
+    })
+}
+
+func (db *Database) remove(key string) (bool, error) {
+    key, err := db.key(key)
+    if err != nil {
+        return false, err
+    }
+    db.mu.Lock()
+    defer db.mu.Unlock()
+    if db.closed {
+        return false, ErrClosed
+    }
+    return db.del(key), nil
+}
+
+// Ping reports whether the database is usable. The in-memory database is
+// always reachable, so only a closed database fails.
+func (db *Database) Ping(ctx context.Context) error {
+    ctx, cancel := db.opContext(ctx)
+    defer cancel()
+    return opError("ping", "", db.ping(ctx))
+}
+
+func (db *Database) ping(ctx context.Context) error {
+    db.mu.RLock()
+    defer db.mu.RUnlock()
+    if db.closed {
+        return ErrClosed
+    }
+    return ctx.Err()
+}
+
+// Sync does nothing: the in-memory database has nothing to flush.
+func (db *Database) Sync(ctx context.Context) error {
+    return opError("sync", "", db.ping(ctx))
+}
+
+// Close releases the database. Closing an already closed database is a no-op.
+func (db *Database) Close() error {
+    db.mu.Lock()
+    defer db.mu.Unlock()
+    if db.closed {
+        return nil
+    }
+    db.closed = true
+    db.data = nil
+    db.tombs = nil
+    close(db.stop)
+    for sub := range db.subs {
+        db.unsubscribe(sub)
+    }
     return nil
 }
 
-func Connect(uri string) *Database {
-    return &Database{URI: uri}
+// ConnectContext opens an in-memory Database. Unlike Connect it does not
+// consult the registry and only accepts the mem schemes.
+func ConnectContext(ctx context.Context, uri string) (*Database, error) {
+    return ConnectWithOptions(ctx, uri, ConnectOptions{})
+}
+
+func ConnectWithOptions(ctx context.Context, uri string, opts ConnectOptions) (*Database, error) {
+    if err := validateURI(uri); err != nil {
+        return nil, fmt.Errorf("connect %q: %w", uri, err)
+    }
+    if err := ctx.Err(); err != nil {
+        return nil, err
+    }
+    return newDatabase(uri, opts), nil
+}
+
+func newDatabase(uri string, opts ConnectOptions) *Database {
+    logger := opts.Logger
+    if logger == nil {
+        logger = slog.New(slog.DiscardHandler)
+    }
+    db := &Database{
+        URI:       uri,
+        opts:      opts,
+        logger:    logger,
+        observer:  opts.Observer,
+        maxValue:  opts.MaxValueSize,
+        maxKeys:   opts.MaxKeys,
+        ttl:       opts.DefaultTTL,
+        opTimeout: opts.OpTimeout,
+        data:      make(map[string]entry),
+        tombs:     make(map[string]uint64),
+        stop:      make(chan struct{}),
+        subs:      make(map[*subscriber]struct{}),
+    }
+    if opts.MaxConns > 0 {
+        db.conns = make(chan struct{}, opts.MaxConns)
+    }
+    if opts.LRUEntries > 0 {
+        db.lru = list.New()
+    }
+    if opts.SweepInterval > 0 {
+        go db.sweep(opts.SweepInterval)
+    }
+    return db
+}
+
+func parseURI(uri string) (*url.URL, error) {
+    if uri == "" {
+        return nil, fmt.Errorf("%w: empty", ErrInvalidURI)
+    }
+    u, err := url.Parse(uri)
+    if err != nil {
+        return nil, fmt.Errorf("%w: %w", ErrInvalidURI, err)
+    }
+    return u, nil
+}
+
+func validateURI(uri string) error {
+    u, err := parseURI(uri)
+    if err != nil {
+        return err
+    }
+    if !slices.Contains(memSchemes, u.Scheme) {
+        return fmt.Errorf("%w: unsupported scheme %q", ErrInvalidURI, u.Scheme)
+    }
+    return nil
 }
@@ -0,0 +1,70 @@
+package main
+
+import (
+    "context"
+    "log/slog"
+    "strings"
+    "time"
+)
+
+type correlationKey struct{}
+
+// WithCorrelationID returns a copy of ctx carrying id. Operations run with
+// the returned context include id in their log records and in the calls of
+// a ContextObserver.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+    return context.WithValue(ctx, correlationKey{}, id)
+}
+
+// traceID returns the trace ID of the span in ctx, if any. It is set by the
+// OpenTelemetry adapter, which is only built with the otel build tag.
+var traceID func(context.Context) string
+
+// CorrelationID returns the ID set with WithCorrelationID or, failing that,
+// the trace ID of the OpenTelemetry span in ctx when built with the otel
+// tag. It returns "" if there is neither.
+func CorrelationID(ctx context.Context) string {
+    if id, ok := ctx.Value(correlationKey{}).(string); ok {
+        return id
+    }
+    if traceID != nil {
+        return traceID(ctx)
+    }
+    return ""
+}
+
+// ContextObserver is an Observer that also wants the context of each
+// operation, for example to read its CorrelationID. The Database calls
+// ObserveOpContext instead of ObserveOp when the Observer implements it.
+type ContextObserver interface {
+    Observer
+    ObserveOpContext(ctx context.Context, op, key string, dur time.Duration, err error)
+}
+
+// Tracer starts a span per operation. NewOTelTracer, built with the otel
+// tag, adapts an OpenTelemetry tracer.
+type Tracer interface {
+    Start(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, Span)
+}
+
+// Span is an operation in progress. End records err, if not nil, as the
+// span's failure.
+type Span interface {
+    End(err error)
+}
+
+func WithTracer(t Tracer) Option {
+    return func(o *ConnectOptions) { o.Tracer = t }
+}
+
+// spanName turns an op such as "save_batch" into "storage.SaveBatch".
+func spanName(op string) string {
+    var b strings.Builder
+    b.WriteString("storage.")
+    for _, part := range strings.Split(op, "_") {
+        if part != "" {
+            b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+        }
+    }
+    return b.String()
+}
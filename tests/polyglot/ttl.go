@@ -0,0 +1,61 @@
+package main
+
+import (
+    "container/list"
+    "context"
+    "time"
+)
+
+type entry struct {
+    value   []byte
+    expires time.Time // zero means the entry never expires
+    rev     uint64
+    elem    *list.Element // in db.lru, if set
+}
+
+func (e entry) expired(now time.Time) bool {
+    return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// SaveWithTTL saves data under key so that it expires after ttl. A zero ttl
+// uses DefaultTTL, like Save.
+func (db *Database) SaveWithTTL(ctx context.Context, key, data string, ttl time.Duration) error {
+    return db.run(ctx, "save", key, func(ctx context.Context) error {
+        _, err := db.save(ctx, key, []byte(data), ttl)
+        return err
+    })
+}
+
+// purge removes key if it is still expired once the write lock is held.
+func (db *Database) purge(key string) {
+    db.mu.Lock()
+    defer db.mu.Unlock()
+    if e, ok := db.data[key]; ok && e.expired(time.Now()) {
+        db.unlink(key, e)
+    }
+}
+
+// purgeExpired removes every entry expired at now. Callers hold db.mu.
+func (db *Database) purgeExpired(now time.Time) {
+    for key, e := range db.data {
+        if e.expired(now) {
+            db.unlink(key, e)
+        }
+    }
+}
+
+// sweep periodically removes expired entries until the database is closed.
+func (db *Database) sweep(interval time.Duration) {
+    t := time.NewTicker(interval)
+    defer t.Stop()
+    for {
+        select {
+        case <-db.stop:
+            return
+        case now := <-t.C:
+            db.mu.Lock()
+            db.purgeExpired(now)
+            db.mu.Unlock()
+        }
+    }
+}
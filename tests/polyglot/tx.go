@@ -0,0 +1,128 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "sync"
+    "time"
+)
+
+// Tx stages writes against a Database. Nothing is visible to other readers
+// until Commit applies the staged writes in one step under the database's
+// write lock. Cancelling the context passed to Begin rolls the Tx back.
+type Tx struct {
+    db   *Database
+    ctx  context.Context
+    stop func() bool
+
+    mu     sync.Mutex
+    done   bool
+    writes map[string]*string // nil marks a delete
+}
+
+func (db *Database) Begin(ctx context.Context) (*Tx, error) {
+    if err := db.ping(ctx); err != nil {
+        return nil, &OpError{Op: "begin", Err: err}
+    }
+    tx := &Tx{db: db, ctx: ctx, writes: make(map[string]*string)}
+    tx.stop = context.AfterFunc(ctx, func() { tx.Rollback() })
+    return tx, nil
+}
+
+func (tx *Tx) Save(key, data string) error {
+    return tx.stage(key, &data)
+}
+
+func (tx *Tx) Delete(key string) error {
+    return tx.stage(key, nil)
+}
+
+func (tx *Tx) stage(key string, data *string) error {
+    k, err := tx.db.key(key)
+    if err != nil {
+        return fmt.Errorf("key %q: %w", key, err)
+    }
+    key = k
+    tx.mu.Lock()
+    defer tx.mu.Unlock()
+    if tx.done {
+        return ErrTxDone
+    }
+    tx.writes[key] = data
+    return nil
+}
+
+func (tx *Tx) Commit() error {
+    tx.mu.Lock()
+    defer tx.mu.Unlock()
+    if tx.done {
+        return ErrTxDone
+    }
+    tx.done = true
+    tx.stop()
+    err := tx.apply()
+    tx.db.reportEvictions()
+    return opError("commit", "", err)
+}
+
+// apply writes the staged changes to the database.
+func (tx *Tx) apply() error {
+    db := tx.db
+    db.mu.Lock()
+    defer db.mu.Unlock()
+    if db.closed {
+        return ErrClosed
+    }
+    if err := tx.ctx.Err(); err != nil {
+        return err
+    }
+    if err := tx.checkLimits(); err != nil {
+        return err
+    }
+    for key, data := range tx.writes {
+        if data == nil {
+            db.del(key)
+        } else {
+            db.put(key, []byte(*data), 0)
+        }
+    }
+    tx.writes = nil
+    return nil
+}
+
+// checkLimits rejects the whole commit if any staged value is too large or
+// the net number of new keys would exceed the quota. Callers hold db.mu.
+func (tx *Tx) checkLimits() error {
+    db := tx.db
+    now := time.Now()
+    added := 0
+    for key, data := range tx.writes {
+        e, ok := db.data[key]
+        exists := ok && !e.expired(now)
+        switch {
+        case data == nil && exists:
+            added--
+        case data != nil && !exists:
+            added++
+        }
+        if data != nil {
+            if err := db.checkValue(len(*data)); err != nil {
+                return fmt.Errorf("key %q: %w", key, err)
+            }
+        }
+    }
+    return db.checkQuota(added)
+}
+
+// Rollback discards the staged writes.
+func (tx *Tx) Rollback() error {
+    tx.mu.Lock()
+    defer tx.mu.Unlock()
+    if tx.done {
+        return ErrTxDone
+    }
+    tx.done = true
+    tx.stop()
+    tx.writes = nil
+    return nil
+}